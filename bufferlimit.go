@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// truncatedMarker ends stdout that was cut short by --buffer-limit.
+const truncatedMarker = "\n...[truncated]\n"
+
+// boundedBuffer keeps at most limit+1 bytes of what is written to it and
+// discards the rest. The extra byte tells truncateOutput that the limit was
+// exceeded.
+type boundedBuffer struct {
+	limit int64
+	buf   []byte
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit + 1 - int64(len(b.buf)); room > 0 {
+		b.buf = append(b.buf, p[:min(int64(len(p)), room)]...)
+	}
+	// Report everything as written so kubectl is not stopped by a short write.
+	return len(p), nil
+}
+
+// truncateOutput cuts out to limit bytes and appends truncatedMarker when it
+// is longer, reporting whether it did. A limit of 0 means no limit.
+func truncateOutput(out []byte, limit int64) ([]byte, bool) {
+	if limit <= 0 || int64(len(out)) <= limit {
+		return out, false
+	}
+	return append(out[:limit:limit], truncatedMarker...), true
+}
+
+// parseByteSize parses a size such as "4096", "64K", "10MiB" or "1G", with
+// binary (1024-based) units.
+func parseByteSize(s string) (int64, error) {
+	num := strings.TrimRight(strings.ToUpper(s), "IB")
+	mult := int64(1)
+	if n := len(num); n > 0 {
+		switch num[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q: expected bytes, optionally with a K, M or G suffix", s)
+	}
+	if v > math.MaxInt64/mult {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return v * mult, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// --- --buffer-limit ---
+
+func TestBufferLimit_TruncatesLargeOutput(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-us-east": {stdout: strings.Repeat("log line\n", 100)},
+		"":             {stdout: "short\n"},
+	})
+	out, errOut, err := runCmd(t, "--buffer-limit", "20", "--header", "{context}", "prod", "logs", "deploy/web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "prod-us-east\nlog line\nlog line\nlo\n...[truncated]\n\n" +
+		"prod-eu-west\nshort\n\n"
+	if out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+	if errOut != "[xctx] context \"prod-us-east\": output truncated (--buffer-limit)\n" {
+		t.Errorf("expected a truncation note for prod-us-east only, got %q", errOut)
+	}
+}
+
+func TestBoundedBuffer_KeepsOneByteOverLimit(t *testing.T) {
+	b := &boundedBuffer{limit: 4}
+	for _, chunk := range []string{"ab", "cdef", "ghi"} {
+		if n, err := b.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if string(b.buf) != "abcde" {
+		t.Errorf("want %q, got %q", "abcde", b.buf)
+	}
+	out, truncated := truncateOutput(b.buf, 4)
+	if string(out) != "abcd"+truncatedMarker || !truncated {
+		t.Errorf("unexpected truncation %q, %v", out, truncated)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{"4096": 4096, "64K": 64 << 10, "10MiB": 10 << 20, "1g": 1 << 30, "2KB": 2048} {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "ten", "-1", "5T", "9223372036854775807K", "8589934592G"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q): expected an error", in)
+		}
+	}
+}
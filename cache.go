@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// contextCache keeps the kubeconfig's context names between runs, under the
+// user cache dir, so listing them does not start kubectl every time. An
+// entry is used for at most ttl, and only while the kubeconfig files still
+// have the modification times it was stored with.
+type contextCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cacheEntry is a stored context list.
+type cacheEntry struct {
+	Key      string    `json:"key"`
+	Stored   time.Time `json:"stored"`
+	Contexts []string  `json:"contexts"`
+}
+
+// newContextCache returns the cache for a run, or nil when it is disabled
+// or there is no user cache dir.
+func newContextCache(ttl time.Duration, disabled bool) *contextCache {
+	if disabled || ttl <= 0 {
+		return nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &contextCache{dir: filepath.Join(dir, "kubectl-xctx"), ttl: ttl}
+}
+
+// get returns the cached context names for cfg, if still valid.
+func (c *contextCache) get(cfg execConfig) ([]string, bool) {
+	path, key, ok := c.locate(cfg)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // path is under the user cache dir
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key || time.Since(entry.Stored) > c.ttl {
+		return nil, false
+	}
+	return entry.Contexts, true
+}
+
+// put stores names for cfg. The cache is only an optimization, so failing
+// to write it is not an error.
+func (c *contextCache) put(cfg execConfig, names []string) {
+	path, key, ok := c.locate(cfg)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(cacheEntry{Key: key, Stored: time.Now(), Contexts: names})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0o600)
+}
+
+// locate returns the cache file for cfg's kubeconfig and the key its entry
+// must carry: the kubectl binary and each kubeconfig file with its mtime.
+// It reports false when no kubeconfig file exists to key the entry on.
+func (c *contextCache) locate(cfg execConfig) (path, key string, ok bool) {
+	paths := kubeconfigPaths(cfg)
+	var b strings.Builder
+	b.WriteString(cfg.binary())
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			fmt.Fprintf(&b, "\n%s\tmissing", p)
+			continue
+		}
+		ok = true
+		fmt.Fprintf(&b, "\n%s\t%d", p, info.ModTime().UnixNano())
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(cfg.binary() + "\n" + strings.Join(paths, "\n")))
+	return filepath.Join(c.dir, fmt.Sprintf("contexts-%x.json", h.Sum64())), b.String(), ok
+}
+
+// kubeconfigPaths returns the kubeconfig files kubectl reads under cfg:
+// those in KUBECONFIG, from cfg's environment or else the inherited one, or
+// ~/.kube/config.
+func kubeconfigPaths(cfg execConfig) []string {
+	value := os.Getenv("KUBECONFIG")
+	for _, kv := range cfg.env {
+		if v, ok := strings.CutPrefix(kv, "KUBECONFIG="); ok {
+			value = v
+		}
+	}
+	var paths []string
+	for _, p := range filepath.SplitList(value) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) > 0 {
+		return paths
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// resultCache keeps the stdout of successful read-only kubectl calls under
+// the user cache dir for --cache-ttl, so identical invocations soon after,
+// such as a dashboard polling the same get, reuse it instead of calling
+// kubectl again.
+type resultCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// readOnlyVerbs are the kubectl commands whose output --cache-ttl may
+// reuse. Anything else, including commands like exec whose effect is
+// unknown, always runs.
+var readOnlyVerbs = map[string]bool{
+	"api-resources": true, "api-versions": true, "cluster-info": true,
+	"describe": true, "explain": true, "get": true, "logs": true,
+	"top": true, "version": true,
+}
+
+// cacheable reports whether the output of the kubectl command in args may
+// be reused.
+func cacheable(args []string) bool {
+	verb, _, _ := parseKubectlArgs(args)
+	return readOnlyVerbs[verb]
+}
+
+// resultEntry is a stored kubectl stdout.
+type resultEntry struct {
+	Stored time.Time `json:"stored"`
+	Stdout []byte    `json:"stdout"`
+}
+
+// newResultCache returns the cache for --cache-ttl, or nil when it is off
+// or there is no user cache dir.
+func newResultCache(ttl time.Duration) *resultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	return &resultCache{dir: filepath.Join(dir, "kubectl-xctx", "results"), ttl: ttl}
+}
+
+// get returns the stdout cached for running args under cfg, if it was
+// stored within the TTL.
+func (c *resultCache) get(cfg execConfig, args []string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(cfg, args)) //nolint:gosec // path is under the user cache dir
+	if err != nil {
+		return nil, false
+	}
+	var entry resultEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Since(entry.Stored) > c.ttl {
+		return nil, false
+	}
+	return entry.Stdout, true
+}
+
+// put stores stdout for args under cfg. Like the context cache, failing to
+// write is not an error.
+func (c *resultCache) put(cfg execConfig, args []string, stdout []byte) {
+	data, err := json.Marshal(resultEntry{Stored: time.Now(), Stdout: stdout})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	_ = os.WriteFile(c.path(cfg, args), data, 0o600)
+}
+
+// path returns the cache file for args under cfg: a hash of the binary, the
+// environment, the args, which include the context, and any --stdin input.
+func (c *resultCache) path(cfg execConfig, args []string) string {
+	h := sha256.New()
+	for _, part := range append(append([]string{cfg.binary()}, cfg.env...), args...) {
+		_, _ = h.Write([]byte(part + "\x00"))
+	}
+	if cfg.stdin != nil {
+		_, _ = h.Write([]byte("stdin\x00"))
+		_, _ = h.Write(cfg.stdin)
+	}
+	return filepath.Join(c.dir, fmt.Sprintf("%x.json", h.Sum(nil)))
+}
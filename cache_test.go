@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tempContextCache returns an execConfig whose kubeconfig is a temp file, with a
+// context cache in a temp dir, and the fake kubectl counting its listings.
+func tempContextCache(t *testing.T, ttl time.Duration) (execConfig, string, *fakeKubectl) {
+	t.Helper()
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte("apiVersion: v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := execConfig{
+		env:          []string{"KUBECONFIG=" + kubeconfig},
+		contextCache: &contextCache{dir: t.TempDir(), ttl: ttl},
+	}
+	return cfg, kubeconfig, useContextKubectl(t, nil)
+}
+
+func TestContextCache_Hit(t *testing.T) {
+	cfg, _, fake := tempContextCache(t, time.Minute)
+	for range 2 {
+		names, err := listContexts(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(names) != 4 {
+			t.Fatalf("want 4 contexts, got %q", names)
+		}
+	}
+	if fake.calls["config get-contexts"] != 1 {
+		t.Errorf("expected the second lookup to be served from the cache, got %d kubectl calls", fake.calls["config get-contexts"])
+	}
+}
+
+func TestContextCache_MissAfterTTL(t *testing.T) {
+	cfg, _, fake := tempContextCache(t, time.Nanosecond)
+	for range 2 {
+		if _, err := listContexts(cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if fake.calls["config get-contexts"] != 2 {
+		t.Errorf("expected an expired entry to be ignored, got %d kubectl calls", fake.calls["config get-contexts"])
+	}
+}
+
+func TestContextCache_InvalidatedByKubeconfigChange(t *testing.T) {
+	cfg, kubeconfig, fake := tempContextCache(t, time.Minute)
+	if _, err := listContexts(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(kubeconfig, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listContexts(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.calls["config get-contexts"] != 2 {
+		t.Errorf("expected a changed kubeconfig to invalidate the cache, got %d kubectl calls", fake.calls["config get-contexts"])
+	}
+}
+
+func TestContextCache_NoKubeconfigNoCache(t *testing.T) {
+	cfg, _, fake := tempContextCache(t, time.Minute)
+	cfg.env = []string{"KUBECONFIG=" + filepath.Join(t.TempDir(), "missing")}
+	for range 2 {
+		if _, err := listContexts(cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if fake.calls["config get-contexts"] != 2 {
+		t.Errorf("expected nothing to be cached without a kubeconfig file, got %d kubectl calls", fake.calls["config get-contexts"])
+	}
+}
+
+func TestContextCache_NoCacheFlag(t *testing.T) {
+	if newContextCache(time.Minute, true) != nil {
+		t.Error("expected --no-cache to disable the cache")
+	}
+	if newContextCache(0, false) != nil {
+		t.Error("expected a zero --context-cache-ttl to disable the cache")
+	}
+}
+
+// --- --cache-ttl ---
+
+// numberedCalls answers every command with the number of commands run so
+// far.
+var numberedCalls = map[string]fakeResponse{"": {stdout: "call {call}\n"}}
+
+func TestResultCache_HitWithinTTL(t *testing.T) {
+	fake := useContextKubectl(t, numberedCalls)
+	opts := &options{resultCache: &resultCache{dir: t.TempDir(), ttl: time.Minute}}
+	first := runAttempt(context.Background(), "prod-us-east", []string{"get", "pods"}, opts)
+	second := runAttempt(context.Background(), "prod-us-east", []string{"get", "pods"}, opts)
+	if fake.total != 1 || string(second.stdout) != string(first.stdout) {
+		t.Errorf("expected the second get to reuse %q, got %q after %d calls", first.stdout, second.stdout, fake.total)
+	}
+
+	runAttempt(context.Background(), "prod-eu-west", []string{"get", "pods"}, opts)
+	runAttempt(context.Background(), "prod-us-east", []string{"get", "nodes"}, opts)
+	if fake.total != 3 {
+		t.Errorf("expected another context or command to miss, got %d calls", fake.total)
+	}
+}
+
+func TestResultCache_MissAfterTTL(t *testing.T) {
+	fake := useContextKubectl(t, numberedCalls)
+	opts := &options{resultCache: &resultCache{dir: t.TempDir(), ttl: time.Nanosecond}}
+	runAttempt(context.Background(), "prod-us-east", []string{"get", "pods"}, opts)
+	time.Sleep(time.Millisecond)
+	r := runAttempt(context.Background(), "prod-us-east", []string{"get", "pods"}, opts)
+	if fake.total != 2 || string(r.stdout) != "call 2\n" {
+		t.Errorf("expected an expired entry to be ignored, got %q after %d calls", r.stdout, fake.total)
+	}
+}
+
+func TestResultCache_WritesBypass(t *testing.T) {
+	fake := useContextKubectl(t, numberedCalls)
+	opts := &options{resultCache: &resultCache{dir: t.TempDir(), ttl: time.Minute}}
+	for range 2 {
+		runAttempt(context.Background(), "prod-us-east", []string{"scale", "deploy/web", "--replicas=2"}, opts)
+	}
+	if fake.total != 2 {
+		t.Errorf("expected every scale to run, got %d calls", fake.total)
+	}
+}
+
+func TestResultCache_KeyedOnStdin(t *testing.T) {
+	fake := useContextKubectl(t, numberedCalls)
+	opts := &options{resultCache: &resultCache{dir: t.TempDir(), ttl: time.Minute}}
+	for _, manifest := range []string{"kind: Pod\n", "kind: Service\n", "kind: Pod\n"} {
+		opts.stdinData = []byte(manifest)
+		runAttempt(context.Background(), "prod-us-east", []string{"get", "-f", "-"}, opts)
+	}
+	if fake.total != 2 {
+		t.Errorf("expected a different --stdin input to miss and the same one to hit, got %d calls", fake.total)
+	}
+}
+
+func TestResultCache_RejectsRerunningFlags(t *testing.T) {
+	useFakeKubectl(t)
+	for _, flag := range []string{"--wait-for=10s", "--repeat=3"} {
+		_, _, err := runCmd(t, "--cache-ttl=1m", flag, "--expect=ok", "prod", "--", "get", "pods")
+		if err == nil || !strings.Contains(err.Error(), "--cache-ttl cannot be combined with --wait-for or --repeat") {
+			t.Errorf("expected %s to be rejected with --cache-ttl, got %v", flag, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+)
+
+// Values accepted by --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// headerColors are the ANSI foreground colors given to context headers.
+var headerColors = []int{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+// useColor resolves a --color mode for output written to out. In auto mode,
+// color is used only on a terminal and when NO_COLOR is unset.
+func useColor(mode string, out io.Writer) (bool, error) {
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(out), nil
+	default:
+		return false, fmt.Errorf("invalid --color %q: must be auto, always or never", mode)
+	}
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the color assigned to ctxName. The color is derived
+// from a hash of the name, so a context keeps its color across runs.
+func colorize(s, ctxName string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(ctxName))
+	code := headerColors[h.Sum32()%uint32(len(headerColors))]
+	return fmt.Sprintf("\033[%dm%s\033[0m", code, s)
+}
+
+// ansiEscape matches ANSI control sequences (CSI, such as colors and cursor
+// movement, and OSC, such as hyperlinks and window titles).
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// stripANSI returns b without ANSI escape sequences, for --strip-ansi.
+func stripANSI(b []byte) []byte {
+	return ansiEscape.ReplaceAll(b, nil)
+}
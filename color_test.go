@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// --- --color ---
+
+func TestColor_AlwaysAndNever(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--color", "always", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "\033[") || !strings.Contains(out, "### Context: prod-us-east\033[0m\n") {
+		t.Errorf("expected colored headers, got %q", out)
+	}
+	if !strings.Contains(out, "\033[0m\nresult from prod-us-east\n") {
+		t.Errorf("expected the output itself left uncolored, got %q", out)
+	}
+
+	out, _, err = runCmd(t, "--color", "never", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("expected no ANSI escapes with --color never, got %q", out)
+	}
+}
+
+func TestColor_AutoOffWhenNotTerminal(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("expected no color when stdout is not a terminal, got %q", out)
+	}
+}
+
+func TestColorize_StablePerContext(t *testing.T) {
+	if colorize("h", "prod-us-east") != colorize("h", "prod-us-east") {
+		t.Error("expected the same color for the same context")
+	}
+	if colorize("h", "prod-us-east") == colorize("h", "prod-eu-west") {
+		t.Error("expected these contexts to get different colors")
+	}
+}
+
+func TestColor_Invalid(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--color", "sometimes", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --color") {
+		t.Errorf("expected invalid --color error, got %v", err)
+	}
+}
+
+// --- --strip-ansi ---
+
+func TestStripANSI_RemovesEscapes(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"": {
+		stdout: "\033[1;32mRunning\033[0m \033]8;;https://example.com\033\\link\033]8;;\033\\\n",
+		stderr: "\033[33mWarning\033[0m: deprecated\n",
+	}})
+	out, errOut, err := runCmd(t, "--strip-ansi", "--header", "", "staging", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "Running link\n" {
+		t.Errorf("expected stdout without escapes, got %q", out)
+	}
+	if errOut != "Warning: deprecated\n" {
+		t.Errorf("expected stderr without escapes, got %q", errOut)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginShim is the kubectl_complete-xctx script. kubectl runs it to
+// complete "kubectl xctx ..." with the words after "xctx", and it answers
+// through the binary's __complete command.
+const pluginShim = `#!/usr/bin/env sh
+# kubectl_complete-xctx: shell completion for "kubectl xctx", run by kubectl.
+kubectl-xctx __complete "$@"
+`
+
+// newCompletionCmd returns the "completion" subcommand. The shell scripts
+// register completion for the kubectl-xctx binary; "plugin" prints the
+// kubectl_complete-xctx script that kubectl invokes to complete
+// "kubectl xctx <TAB>", which must be on PATH.
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|plugin]",
+		Short: "Print a shell completion script for kubectl-xctx",
+		Long: `Print a shell completion script for kubectl-xctx.
+
+  source <(kubectl-xctx completion bash)
+  kubectl-xctx completion zsh > "${fpath[1]}/_kubectl-xctx"
+  kubectl-xctx completion fish > ~/.config/fish/completions/kubectl-xctx.fish
+
+Completing "kubectl xctx" (the plugin form) uses kubectl's own completion,
+which runs a kubectl_complete-xctx script found on your PATH:
+
+  kubectl-xctx completion plugin > ~/.krew/bin/kubectl_complete-xctx
+  chmod +x ~/.krew/bin/kubectl_complete-xctx`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish", "plugin"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeCompletion(cmd.Root(), args[0], cmd.OutOrStdout())
+		},
+	}
+}
+
+// writeCompletion writes the completion script for shell to out.
+func writeCompletion(root *cobra.Command, shell string, out io.Writer) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(out, true)
+	case "zsh":
+		return root.GenZshCompletion(out)
+	case "fish":
+		return root.GenFishCompletion(out, true)
+	case "plugin":
+		_, err := io.WriteString(out, pluginShim)
+		return err
+	default:
+		return fmt.Errorf("unsupported shell %q: must be bash, zsh, fish or plugin", shell)
+	}
+}
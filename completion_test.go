@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// --- completion ---
+
+func TestCompletion_BashReferencesPlugin(t *testing.T) {
+	out, _, err := runCmd(t, "completion", "bash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "__complete") {
+		t.Errorf("expected script to use the __complete protocol, got:\n%s", out)
+	}
+	if !strings.Contains(out, "complete -o default -F __start_kubectl-xctx kubectl-xctx") {
+		t.Errorf("expected completion registered for kubectl-xctx, got:\n%s", out)
+	}
+}
+
+func TestCompletion_ZshAndFish(t *testing.T) {
+	for _, shell := range []string{"zsh", "fish"} {
+		out, _, err := runCmd(t, "completion", shell)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", shell, err)
+		}
+		if !strings.Contains(out, "kubectl-xctx") {
+			t.Errorf("%s: expected script to reference kubectl-xctx", shell)
+		}
+	}
+}
+
+func TestCompletion_PluginShimForKubectl(t *testing.T) {
+	out, _, err := runCmd(t, "completion", "plugin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "kubectl_complete-xctx") || !strings.Contains(out, `kubectl-xctx __complete "$@"`) {
+		t.Errorf("expected the kubectl_complete-xctx script forwarding to __complete, got:\n%s", out)
+	}
+	shipped, err := os.ReadFile("kubectl_complete-xctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(shipped) != out {
+		t.Errorf("expected the shipped kubectl_complete-xctx to match, got:\n%s", shipped)
+	}
+}
+
+func TestCompletion_UnsupportedShell(t *testing.T) {
+	if _, _, err := runCmd(t, "completion", "powershell"); err == nil {
+		t.Fatal("expected error for unsupported shell, got nil")
+	}
+}
+
+func TestCompletion_HonorsKubeconfigAndKubectlBin(t *testing.T) {
+	var got []execConfig
+	mockKubectlExec(t, func(_ context.Context, cfg execConfig, _ ...string) ([]byte, []byte, error) {
+		got = append(got, cfg)
+		return []byte(fakeContextList), nil, nil
+	})
+	out, _, err := runCmd(t, "__complete", "--kubeconfig", "/etc/kube/a.yaml:/etc/kube/b.yaml", "--kubectl-bin", "/opt/kubectl", "prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "prod-us-east\nprod-eu-west\n") {
+		t.Errorf("expected prod contexts completed, got %q", out)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 kubectl call, got %d", len(got))
+	}
+	if got[0].binary() != "/opt/kubectl" {
+		t.Errorf("expected --kubectl-bin to be used, got %q", got[0].binary())
+	}
+	if strings.Join(got[0].env, ",") != "KUBECONFIG=/etc/kube/a.yaml:/etc/kube/b.yaml" {
+		t.Errorf("expected KUBECONFIG from --kubeconfig, got %v", got[0].env)
+	}
+}
+
+func TestCompletion_EnumFlagValues(t *testing.T) {
+	for _, tc := range []struct {
+		flag string
+		want string
+	}{
+		{"--output", "jsonl\nyaml\nyaml-docs\n"},
+		{"--sort-output", "input\nduration\nstatus\nname\n"},
+		{"--color", "auto\nalways\nnever\n"},
+	} {
+		out, _, err := runCmd(t, "__complete", tc.flag, "")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.flag, err)
+		}
+		if !strings.HasPrefix(out, tc.want+":4\n") {
+			t.Errorf("%s: want suggestions %q without file completion, got %q", tc.flag, tc.want, out)
+		}
+	}
+}
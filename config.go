@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the YAML config file. Each field supplies the default for
+// the flag of the same name; nil means the file does not set it.
+type fileConfig struct {
+	Parallel *bool          `yaml:"parallel"`
+	Timeout  *time.Duration `yaml:"timeout"`
+	Header   *string        `yaml:"header"`
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/kubectl-xctx/config.yaml,
+// falling back to ~/.config when XDG_CONFIG_HOME is unset.
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "kubectl-xctx", "config.yaml")
+}
+
+// loadConfig reads the config file at path. A missing or empty file is not
+// an error and yields an empty config; an unknown key is.
+func loadConfig(path string) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the user's own --config flag
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// Reject unknown keys, so a misspelled setting is not silently ignored.
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyConfig copies config values into opts for every flag that was not
+// set on the command line, so explicit flags always take precedence.
+func applyConfig(cmd *cobra.Command, cfg *fileConfig, opts *options) {
+	changed := cmd.Flags().Changed
+	if cfg.Parallel != nil && !changed("parallel") {
+		opts.parallel = *cfg.Parallel
+	}
+	if cfg.Timeout != nil && !changed("timeout") {
+		opts.timeout = *cfg.Timeout
+	}
+	if cfg.Header != nil && !changed("header") {
+		opts.header = *cfg.Header
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file into a temp dir and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// --- loadConfig ---
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), "nope.yaml"))
+	if err != nil {
+		t.Fatalf("missing config should be a no-op, got: %v", err)
+	}
+	if cfg.Parallel != nil || cfg.Timeout != nil || cfg.Header != nil {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+}
+
+func TestLoadConfig_Fields(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, "parallel: true\ntimeout: 15s\nheader: '== {context} =='\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Parallel == nil || !*cfg.Parallel {
+		t.Errorf("expected parallel: true, got %v", cfg.Parallel)
+	}
+	if cfg.Timeout == nil || *cfg.Timeout != 15*time.Second {
+		t.Errorf("expected timeout 15s, got %v", cfg.Timeout)
+	}
+	if cfg.Header == nil || *cfg.Header != "== {context} ==" {
+		t.Errorf("unexpected header %v", cfg.Header)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	if _, err := loadConfig(writeConfig(t, "timeout: [not a duration\n")); err == nil {
+		t.Fatal("expected error for invalid YAML, got nil")
+	}
+}
+
+func TestLoadConfig_UnknownKey(t *testing.T) {
+	_, err := loadConfig(writeConfig(t, "parallel: true\ntimout: 15s\n"))
+	if err == nil || !strings.Contains(err.Error(), "field timout not found") {
+		t.Fatalf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestLoadConfig_EmptyFile(t *testing.T) {
+	cfg, err := loadConfig(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("empty config should be a no-op, got: %v", err)
+	}
+	if cfg.Parallel != nil || cfg.Timeout != nil || cfg.Header != nil {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+}
+
+// --- applyConfig ---
+
+func TestConfig_HeaderUsedWhenFlagNotPassed(t *testing.T) {
+	useFakeKubectl(t)
+	path := writeConfig(t, "header: '== {context} =='\n")
+	out, _, err := runCmd(t, "--config", path, "prod-us-east", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "== prod-us-east ==\n") {
+		t.Errorf("expected config header, got: %q", out)
+	}
+}
+
+func TestConfig_FlagTakesPrecedence(t *testing.T) {
+	useFakeKubectl(t)
+	path := writeConfig(t, "header: '== {context} =='\n")
+	out, _, err := runCmd(t, "--config", path, "--header", "-- {context} --", "prod-us-east", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "-- prod-us-east --\n") {
+		t.Errorf("expected flag header to win over config, got: %q", out)
+	}
+}
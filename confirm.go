@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// mutatingVerbs are the kubectl commands that change cluster state, which
+// --confirm-count asks about.
+var mutatingVerbs = map[string]bool{
+	"annotate": true, "apply": true, "autoscale": true, "certificate": true,
+	"cordon": true, "create": true, "delete": true, "drain": true,
+	"edit": true, "expose": true, "label": true, "patch": true,
+	"replace": true, "rollout": true, "run": true, "scale": true,
+	"set": true, "taint": true, "uncordon": true,
+}
+
+// readOnlyRollouts are the rollout subcommands that only report.
+var readOnlyRollouts = map[string]bool{"history": true, "status": true}
+
+// mutating reports whether the kubectl command in args changes cluster
+// state.
+func mutating(args []string) bool {
+	verb, positional, _ := parseKubectlArgs(args)
+	if verb == "rollout" && len(positional) > 0 && readOnlyRollouts[positional[0]] {
+		return false
+	}
+	return mutatingVerbs[verb]
+}
+
+// confirmCount asks on errOut for the number of contexts a --confirm-count
+// run targets and reads the answer from in, returning an error unless it is
+// exactly n.
+func confirmCount(in io.Reader, errOut io.Writer, args []string, n int) error {
+	_, _ = fmt.Fprintf(errOut, "[xctx] about to run \"kubectl %s\" in %d context(s); type %d to continue: ", strings.Join(args, " "), n, n)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(errOut)
+		return fmt.Errorf("aborted: no confirmation given for %d context(s)", n)
+	}
+	if answer = strings.TrimSpace(answer); answer != strconv.Itoa(n) {
+		return fmt.Errorf("aborted: typed %q, but the command targets %d context(s)", answer, n)
+	}
+	return nil
+}
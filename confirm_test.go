@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMutating(t *testing.T) {
+	for args, want := range map[string]bool{
+		"delete pod web":                       true,
+		"-n web scale deploy/web --replicas=0": true,
+		"rollout restart deploy/web":           true,
+		"rollout status deploy/web":            false,
+		"get pods":                             false,
+		"logs -n apply web":                    false,
+	} {
+		if got := mutating(strings.Fields(args)); got != want {
+			t.Errorf("mutating(%q) = %v, want %v", args, got, want)
+		}
+	}
+}
+
+// --- --confirm-count ---
+
+// confirmRun runs a delete across the "prod" contexts with --confirm-count,
+// answering with answer, and returns the commands kubectl ran.
+func confirmRun(t *testing.T, answer string) ([]string, string, error) {
+	t.Helper()
+	fake := useContextKubectl(t, nil)
+	var errOut strings.Builder
+	opts := &options{confirmCount: true, stdin: strings.NewReader(answer)}
+	err := execute(context.Background(), "prod", []string{"delete", "pod", "web"}, opts, io.Discard, &errOut)
+	return fake.commands, errOut.String(), err
+}
+
+func TestConfirmCount_CorrectCountRuns(t *testing.T) {
+	ran, errOut, err := confirmRun(t, "2\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut, `about to run "kubectl delete pod web" in 2 context(s); type 2 to continue`) {
+		t.Errorf("expected a prompt naming the count, got %q", errOut)
+	}
+	if len(ran) != 2 {
+		t.Errorf("expected both contexts to run, got %q", ran)
+	}
+}
+
+func TestConfirmCount_WrongCountAborts(t *testing.T) {
+	for _, answer := range []string{"y\n", "3\n", ""} {
+		ran, _, err := confirmRun(t, answer)
+		if err == nil || !strings.HasPrefix(err.Error(), "aborted: ") {
+			t.Errorf("answer %q: expected the run to be aborted, got %v", answer, err)
+		}
+		if len(ran) != 0 {
+			t.Errorf("answer %q: expected no context to run, got %q", answer, ran)
+		}
+	}
+}
+
+func TestConfirmCount_ReadOnlyCommandNotAsked(t *testing.T) {
+	useFakeKubectl(t)
+	var errOut strings.Builder
+	opts := &options{confirmCount: true, stdin: strings.NewReader("")}
+	if err := execute(context.Background(), "prod", []string{"get", "pods"}, opts, io.Discard, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(errOut.String(), "type 2") {
+		t.Errorf("expected no prompt for a read-only command, got %q", errOut.String())
+	}
+}
+
+func TestConfirmCount_AsksForMutatingGroupCmd(t *testing.T) {
+	useFakeKubectl(t)
+	var errOut strings.Builder
+	opts := &options{
+		confirmCount: true,
+		stdin:        strings.NewReader(""),
+		groupBy:      &grouping{delim: "-"},
+		groupCmds:    map[string][]string{"prod": {"scale", "deploy/web", "--replicas=0"}},
+	}
+	err := execute(context.Background(), "prod", []string{"get", "pods"}, opts, io.Discard, &errOut)
+	if err == nil || !strings.HasPrefix(err.Error(), "aborted: ") {
+		t.Errorf("expected the run to be aborted without confirmation, got %v", err)
+	}
+	if !strings.Contains(errOut.String(), `about to run "kubectl scale deploy/web --replicas=0" in 2 context(s)`) {
+		t.Errorf("expected a prompt naming the group's command, got %q", errOut.String())
+	}
+}
+
+func TestConfirmCount_NotWithPatternFromStdin(t *testing.T) {
+	useFakeKubectl(t)
+	opts := &options{confirmCount: true, stdin: strings.NewReader("prod\n")}
+	err := execute(context.Background(), "-", []string{"delete", "pod", "web"}, opts, io.Discard, io.Discard)
+	if err == nil || err.Error() != "--confirm-count cannot be combined with reading the pattern from stdin" {
+		t.Errorf("expected --confirm-count with pattern - to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values accepted by --contexts-file-format.
+const (
+	contextsFileLines = "lines"
+	contextsFileCSV   = "csv"
+	contextsFileYAML  = "yaml"
+)
+
+// readContextsFile returns the context names listed in the file at path,
+// given with flag (--contexts-file or --order-file), parsed according to
+// format.
+func readContextsFile(flag, path, format string) ([]string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is the user's own flag value
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --%s: %w", flag, err)
+	}
+	names, err := parseContextsFile(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s %s: %w", flag, path, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("--%s %s lists no contexts", flag, path)
+	}
+	return names, nil
+}
+
+// orderContexts sorts contexts to follow order, for --order-file. Contexts
+// the file does not list keep their relative order after the listed ones,
+// or are dropped when strict.
+func orderContexts(contexts, order []string, strict bool) []string {
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, dup := rank[name]; !dup {
+			rank[name] = i
+		}
+	}
+	var listed, unlisted []string
+	for _, c := range contexts {
+		if _, ok := rank[c]; ok {
+			listed = append(listed, c)
+		} else if !strict {
+			unlisted = append(unlisted, c)
+		}
+	}
+	sort.SliceStable(listed, func(i, j int) bool { return rank[listed[i]] < rank[listed[j]] })
+	return append(listed, unlisted...)
+}
+
+// parseContextsFile splits data into context names: one per line (blank
+// lines and # comments skipped), comma-separated values, or a YAML list of
+// strings.
+func parseContextsFile(data []byte, format string) ([]string, error) {
+	var names []string
+	switch format {
+	case contextsFileLines:
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				names = append(names, line)
+			}
+		}
+	case contextsFileCSV:
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		for {
+			fields, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				if f = strings.TrimSpace(f); f != "" {
+					names = append(names, f)
+				}
+			}
+		}
+	case contextsFileYAML:
+		if err := yaml.Unmarshal(data, &names); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return names, nil
+}
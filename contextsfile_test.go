@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseContextsFile_Formats(t *testing.T) {
+	want := "prod-us-east prod-eu-west staging-us"
+	for _, tc := range []struct {
+		format string
+		data   string
+	}{
+		{contextsFileLines, "# production\nprod-us-east\n  prod-eu-west\n\nstaging-us\n"},
+		{contextsFileCSV, "prod-us-east, prod-eu-west\nstaging-us\n"},
+		{contextsFileYAML, "- prod-us-east\n- prod-eu-west\n- staging-us\n"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			names, err := parseContextsFile([]byte(tc.data), tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(names, " ") != want {
+				t.Errorf("want %q, got %q", want, names)
+			}
+		})
+	}
+}
+
+func TestParseContextsFile_InvalidYAML(t *testing.T) {
+	_, err := parseContextsFile([]byte("contexts: prod"), contextsFileYAML)
+	if err == nil || !strings.Contains(err.Error(), "cannot unmarshal") {
+		t.Errorf("expected the YAML parse error, got %v", err)
+	}
+}
+
+func TestContextsFile_RunsListedContexts(t *testing.T) {
+	useFakeKubectl(t)
+	path := filepath.Join(t.TempDir(), "contexts.csv")
+	if err := os.WriteFile(path, []byte("staging-us,dev-local\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := runCmd(t, "--contexts-file", path, "--contexts-file-format", "csv", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "result from staging-us") || !strings.Contains(out, "result from dev-local") || strings.Contains(out, "prod") {
+		t.Errorf("expected only the listed contexts to run, got %q", out)
+	}
+}
+
+func TestContextsFile_Errors(t *testing.T) {
+	useFakeKubectl(t)
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(empty, []byte("# nothing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--contexts-file", empty}, "lists no contexts"},
+		{[]string{"--contexts-file", empty, "--contexts-file-format", "toml"}, "invalid --contexts-file-format"},
+		{[]string{"--contexts-file", filepath.Join(t.TempDir(), "missing")}, "failed to read --contexts-file"},
+	} {
+		_, _, err := runCmd(t, append(tc.args, "get", "pods")...)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: want error containing %q, got %v", tc.args, tc.want, err)
+		}
+	}
+}
+
+// --- --order-file ---
+
+func TestOrderFile(t *testing.T) {
+	useFakeKubectl(t)
+	path := filepath.Join(t.TempDir(), "order.txt")
+	if err := os.WriteFile(path, []byte("# dev first, prod last\ndev-local\nstaging-us\nprod-eu-west\nprod-us-west\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "dev-local,staging-us,prod-eu-west,prod-us-east"},
+		{[]string{"--order-strict"}, "dev-local,staging-us,prod-eu-west"},
+	} {
+		args := append([]string{"--order-file", path, "--parallel", "--header", ""}, tc.args...)
+		out, _, err := runCmd(t, append(args, ".", "get", "pods")...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := strings.ReplaceAll(strings.TrimSuffix(strings.ReplaceAll(out, "result from ", ""), "\n"), "\n", ",")
+		if got != tc.want {
+			t.Errorf("%v: want order %s, got %s", tc.args, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+)
+
+// valueFlags are the common kubectl flags, global ones included, that take
+// their value as the next argument, so it is not mistaken for the verb or a
+// resource.
+var valueFlags = map[string]bool{
+	"-n": true, "--namespace": true,
+	"-l": true, "--selector": true,
+	"-f": true, "--filename": true,
+	"-k": true, "--kustomize": true,
+	"-o": true, "--output": true,
+	"--field-selector": true, "--grace-period": true, "--timeout": true,
+	"--cascade": true,
+	"--context": true, "--kubeconfig": true, "--cluster": true, "--user": true,
+	"-s": true, "--server": true, "--token": true, "--request-timeout": true,
+	"--as": true, "--as-group": true, "-v": true, "--v": true,
+}
+
+// dangerousCombo returns a description of the kubectl command in args when
+// it is destructive enough to need --force-dangerous across several
+// contexts: "delete --all", "apply --prune", or a delete that names no
+// resource. It returns "" for anything else.
+func dangerousCombo(args []string) string {
+	verb, positional, flags := parseKubectlArgs(args)
+	switch verb {
+	case "delete":
+		if flags["--all"] {
+			return "delete --all"
+		}
+		if flags["-f"] || flags["--filename"] || flags["-k"] || flags["--kustomize"] || flags["-l"] || flags["--selector"] || flags["--field-selector"] {
+			return ""
+		}
+		if len(positional) <= 1 && (len(positional) == 0 || !strings.Contains(positional[0], "/")) {
+			return "delete without a resource name"
+		}
+	case "apply":
+		if flags["--prune"] {
+			return "apply --prune"
+		}
+	}
+	return ""
+}
+
+// parseKubectlArgs splits kubectl args into the command verb, the remaining
+// positional arguments, and the set of flags given (those set to "false"
+// excluded).
+func parseKubectlArgs(args []string) (verb string, positional []string, flags map[string]bool) {
+	flags = map[string]bool{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if strings.HasPrefix(a, "-") {
+			name, value, hasValue := strings.Cut(a, "=")
+			if !hasValue && valueFlags[name] {
+				i++
+			}
+			if value != "false" {
+				flags[name] = true
+			}
+			continue
+		}
+		if verb == "" {
+			verb = a
+		} else {
+			positional = append(positional, a)
+		}
+	}
+	return verb, positional, flags
+}
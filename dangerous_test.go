@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDangerousCombo(t *testing.T) {
+	for _, c := range []struct {
+		args string
+		want string
+	}{
+		{"delete pods --all -n web", "delete --all"},
+		{"delete --all=true pods", "delete --all"},
+		{"apply -f app.yaml --prune -l app=web", "apply --prune"},
+		{"apply --prune=true -f app.yaml", "apply --prune"},
+		{"delete pods", "delete without a resource name"},
+		{"-n kube-system delete deploy", "delete without a resource name"},
+		{"delete pods web", ""},
+		{"delete pod/web", ""},
+		{"delete -n pods deploy web", ""},
+		{"delete -f app.yaml", ""},
+		{"delete pods -l app=web", ""},
+		{"delete pods --all=false web", ""},
+		{"apply -f app.yaml", ""},
+		{"get pods --all-namespaces", ""},
+	} {
+		if got := dangerousCombo(strings.Fields(c.args)); got != c.want {
+			t.Errorf("dangerousCombo(%q) = %q, want %q", c.args, got, c.want)
+		}
+	}
+}
+
+func TestDangerousCombo_GuardsMultipleContexts(t *testing.T) {
+	for _, args := range [][]string{
+		{"delete", "pods", "--all"},
+		{"apply", "-f", "app.yaml", "--prune", "-l", "app=web"},
+		{"delete", "deploy"},
+	} {
+		calls := useFailingKubectl(t)
+		_, _, err := runCmd(t, append([]string{"prod"}, args...)...)
+		if err == nil || !strings.Contains(err.Error(), "refusing to run") || !strings.Contains(err.Error(), "across 2 contexts") {
+			t.Errorf("%v: expected the guard to refuse, got %v", args, err)
+		}
+		if *calls != 0 {
+			t.Errorf("%v: expected nothing to run, got %d call(s)", args, *calls)
+		}
+	}
+}
+
+func TestDangerousCombo_SingleContextOrForced(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "prod-us-east", "delete", "pods", "--all"); err != nil {
+		t.Errorf("expected a single context to be allowed, got %v", err)
+	}
+	if _, _, err := runCmd(t, "--force-dangerous", "prod", "delete", "pods", "--all"); err != nil {
+		t.Errorf("expected --force-dangerous to allow the run, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// deadlineNow is the clock --deadline is checked against. Overridable in
+// tests.
+var deadlineNow = time.Now
+
+// parseDeadline parses a --deadline value: an RFC 3339 timestamp, or a
+// local "HH:MM" taken as its next occurrence after now.
+func parseDeadline(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("--deadline %s has already passed", value)
+		}
+		return t, nil
+	}
+	clock, err := time.ParseInLocation("15:04", value, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --deadline %q: expected an RFC 3339 time or HH:MM", value)
+	}
+	t := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// errDeadlineReached is the cause of a run context cancelled by --deadline,
+// telling it apart from an interrupt and from a context's --timeout.
+var errDeadlineReached = errors.New("--deadline reached")
+
+// withDeadline bounds the whole run by --deadline, if set.
+func withDeadline(ctx context.Context, opts *options) (context.Context, context.CancelFunc) {
+	if opts.deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadlineCause(ctx, opts.deadline, errDeadlineReached)
+}
+
+// deadlineReached reports whether --deadline has passed, so no further
+// context may start.
+func deadlineReached(ctx context.Context, opts *options) bool {
+	return errors.Is(context.Cause(ctx), errDeadlineReached) ||
+		!opts.deadline.IsZero() && !deadlineNow().Before(opts.deadline)
+}
+
+// interruptedRun reports whether ctx was cancelled by an interrupt rather
+// than by --deadline.
+func interruptedRun(ctx context.Context) bool {
+	return ctx.Err() != nil && !errors.Is(context.Cause(ctx), errDeadlineReached)
+}
+
+// notStarted reports the contexts left when --deadline was reached and
+// returns the run's error, wrapping the failures so far.
+func notStarted(contexts []string, failed []result, opts *options, errOut io.Writer) error {
+	for _, c := range contexts {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q skipped: not started before --deadline %s\n", displayName(c, opts), opts.deadline.Format(time.RFC3339))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("--deadline reached with %d context(s) not started (%w)", len(contexts), newMultiError(failed))
+	}
+	return fmt.Errorf("--deadline reached with %d context(s) not started", len(contexts))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Date(2024, 5, 1, 22, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		value string
+		want  string
+	}{
+		{"23:15", "2024-05-01T23:15:00Z"},
+		{"06:00", "2024-05-02T06:00:00Z"},
+		{"2024-05-02T01:00:00Z", "2024-05-02T01:00:00Z"},
+		{"2024-05-01T20:00:00Z", "error"},
+		{"soon", "error"},
+	} {
+		d, err := parseDeadline(tc.value, now)
+		got := d.Format(time.RFC3339)
+		if err != nil {
+			got = "error"
+		}
+		if got != tc.want {
+			t.Errorf("parseDeadline(%q) = %s, want %s", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestDeadline_SkipsContextsNotStarted(t *testing.T) {
+	useFakeKubectl(t)
+	deadline := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	// The clock passes the deadline after the first context has started.
+	checks := 0
+	orig := deadlineNow
+	deadlineNow = func() time.Time {
+		checks++
+		if checks == 1 {
+			return deadline.Add(-time.Minute)
+		}
+		return deadline.Add(time.Minute)
+	}
+	t.Cleanup(func() { deadlineNow = orig })
+
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"prod-us-east", "prod-eu-west", "staging-us"}, []string{"get", "pods"}, &options{deadline: deadline}, &out, &errOut)
+	if err == nil || err.Error() != "--deadline reached with 2 context(s) not started" {
+		t.Errorf("expected the run to report the contexts not started, got %v", err)
+	}
+	if out.String() != "result from prod-us-east\n" {
+		t.Errorf("expected only the first context to run, got %q", out.String())
+	}
+	for _, c := range []string{"prod-eu-west", "staging-us"} {
+		if !strings.Contains(errOut.String(), `[xctx] context "`+c+`" skipped: not started before --deadline 2024-05-01T06:00:00Z`) {
+			t.Errorf("expected %s to be reported as skipped, got %q", c, errOut.String())
+		}
+	}
+}
+
+func TestDeadline_CancelsRunningContext(t *testing.T) {
+	useContextKubectl(t, untilCancelled)
+	opts := &options{deadline: time.Now().Add(20 * time.Millisecond), timeoutAction: timeoutSkip}
+	ctx, cancel := withDeadline(context.Background(), opts)
+	defer cancel()
+	var out, errOut strings.Builder
+	err := runParallel(ctx, []string{"slow-a", "slow-b"}, []string{"get", "pods"}, opts, &out, &errOut)
+	if got := strings.Join(failedContexts(t, err), ","); got != "slow-a,slow-b" {
+		t.Errorf("expected both running contexts to be stopped at the deadline, got %q", got)
+	}
+	if !errors.Is(err, errDeadlineReached) || strings.Contains(errOut.String(), "timed out") {
+		t.Errorf("expected the deadline reported apart from --timeout, got %v and %q", err, errOut.String())
+	}
+}
+
+func TestDeadline_ParallelReportsContextsNotStarted(t *testing.T) {
+	// --rate holds back the later starts past the deadline.
+	useContextKubectl(t, untilCancelled)
+	opts := &options{deadline: time.Now().Add(20 * time.Millisecond), parallel: true, rate: 0.1}
+	ctx, cancel := withDeadline(context.Background(), opts)
+	defer cancel()
+	var out, errOut strings.Builder
+	err := runParallel(ctx, []string{"slow-a", "slow-b", "slow-c"}, []string{"get", "pods"}, opts, &out, &errOut)
+	if err == nil || !strings.HasPrefix(err.Error(), "--deadline reached with 2 context(s) not started") {
+		t.Errorf("expected the contexts never started to be reported, got %v", err)
+	}
+	if got := strings.Count(errOut.String(), "skipped: not started before --deadline"); got != 2 {
+		t.Errorf("expected 2 contexts reported as not started, got %d in %q", got, errOut.String())
+	}
+	if !errors.Is(err, errDeadlineReached) {
+		t.Errorf("expected the started context to fail with the deadline, got %v", err)
+	}
+}
+
+func TestDeadline_StopsWatch(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"": {stdout: "ok\n"}})
+	var out, errOut strings.Builder
+	opts := &options{watch: true, noClear: true, interval: time.Hour, deadline: time.Now().Add(20 * time.Millisecond)}
+	if err := execute(context.Background(), "prod-us-east", []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("expected a clean exit at the deadline, got: %v", err)
+	}
+	if !strings.Contains(errOut.String(), "[xctx] --deadline reached, stopping --watch") {
+		t.Errorf("expected the watch to stop at the deadline, got %q", errOut.String())
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// runDiff runs kubectlArgs across contexts and prints, for each context, a
+// unified diff of its stdout against the baseline context's stdout.
+func runDiff(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	base := contexts[0]
+	if opts.diffBase != "" {
+		base = opts.diffBase
+	}
+	baseIdx := -1
+	for i, c := range contexts {
+		if c == base {
+			baseIdx = i
+		}
+	}
+	if baseIdx < 0 {
+		return fmt.Errorf("diff base %q is not among the selected contexts", base)
+	}
+
+	results, _ := runContexts(ctx, contexts, kubectlArgs, opts, opts.parallel, nil)
+	if interruptedRun(ctx) {
+		return interrupted(errOut)
+	}
+	if unrun := notRun(contexts, results); len(unrun) > 0 {
+		return notStarted(unrun, nil, opts, errOut)
+	}
+
+	baseline := results[baseIdx]
+	var failed []result
+	for i, r := range results {
+		if r.failed() {
+			failed = append(failed, r)
+		}
+		if opts.header != "" {
+			_, _ = fmt.Fprintln(out, renderHeader(r, opts))
+		}
+		if i == baseIdx {
+			_, _ = fmt.Fprintln(out, "(baseline)")
+		} else {
+			writeDiff(out, baseline, r, opts)
+		}
+		if len(r.stderr) > 0 {
+			_, _ = errOut.Write(r.stderr)
+		}
+		printFailure(r, opts, errOut)
+		writeSeparator(out, opts.header, opts)
+	}
+	if len(failed) > 0 {
+		return newMultiError(failed)
+	}
+	return nil
+}
+
+// writeDiff writes a unified diff from base's stdout to r's stdout, or
+// "no differences" when they are identical.
+func writeDiff(out io.Writer, base, r result, opts *options) {
+	// Writes go to an in-memory buffer, so this cannot fail.
+	text, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(base.stdout)),
+		B:        difflib.SplitLines(string(r.stdout)),
+		FromFile: displayName(base.ctxName, opts),
+		ToFile:   displayName(r.ctxName, opts),
+		Context:  3,
+	})
+	if text == "" {
+		_, _ = fmt.Fprintln(out, "no differences")
+		return
+	}
+	_, _ = io.WriteString(out, text)
+}
+
+// collectResults runs kubectlArgs across contexts without printing, at once
+// or one at a time according to opts, and returns the results in input order
+// of the contexts that ran.
+func collectResults(ctx context.Context, contexts, kubectlArgs []string, opts *options) []result {
+	results, _ := runContexts(ctx, contexts, kubectlArgs, opts, opts.parallel, nil)
+	return slices.DeleteFunc(results, func(r result) bool { return r.ctxName == "" })
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useDriftingKubectl installs a mock where each context returns a slightly
+// different config map.
+func useDriftingKubectl(t *testing.T) {
+	t.Helper()
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-us-east": {stdout: "replicas: 3\nimage: app:v1\nlogLevel: info\n"},
+		"prod-eu-west": {stdout: "replicas: 3\nimage: app:v2\nlogLevel: info\n"},
+		"staging-us":   {stdout: "replicas: 3\nimage: app:v1\nlogLevel: info\n"},
+	})
+}
+
+// --- runDiff ---
+
+func TestRunDiff_HunksAgainstFirstContext(t *testing.T) {
+	useDriftingKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{header: "### Context: {context}", diff: true}
+	err := runDiff(context.Background(), []string{"prod-us-east", "prod-eu-west", "staging-us"}, []string{"get", "cm"}, opts, &out, &errOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"### Context: prod-us-east\n(baseline)\n",
+		"--- prod-us-east\n+++ prod-eu-west\n",
+		"-image: app:v1\n+image: app:v2\n",
+		"### Context: staging-us\nno differences\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestRunDiff_ExplicitBase(t *testing.T) {
+	useDriftingKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{diff: true, diffBase: "prod-eu-west", parallel: true}
+	err := runDiff(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "cm"}, opts, &out, &errOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "--- prod-eu-west\n+++ prod-us-east\n") {
+		t.Errorf("expected diff against prod-eu-west, got:\n%s", out.String())
+	}
+}
+
+func TestRunDiff_UnknownBase(t *testing.T) {
+	useDriftingKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{diff: true, diffBase: "dev-local"}
+	if err := runDiff(context.Background(), []string{"prod-us-east"}, []string{"get", "cm"}, opts, &out, &errOut); err == nil {
+		t.Fatal("expected error for a base outside the selected contexts")
+	}
+}
+
+func TestDiff_NotWithStopEarlyFlags(t *testing.T) {
+	for _, flag := range [][]string{{"--fail-fast"}, {"--max-failures", "1"}} {
+		fake := useContextKubectl(t, map[string]fakeResponse{
+			"staging-us": {err: errors.New("connection refused")},
+			"":           {stdout: "replicas: 3\n"},
+		})
+		out, _, err := runCmd(t, append(append([]string{"--diff"}, flag...), ".", "get", "cm")...)
+		if !errors.Is(err, ErrUsage) {
+			t.Errorf("%v: want a usage error, got %v", flag, err)
+		}
+		if fake.total != 0 || out != "" {
+			t.Errorf("%v: expected nothing to run, got %d call(s) and output %q", flag, fake.total, out)
+		}
+	}
+}
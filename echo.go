@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// syncWriter serializes writes from the goroutines of a parallel run.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// echoCommand writes the kubectl command cfg and args describe to w as one
+// "+ "-prefixed, shell-quoted line, like sh -x, with the extra environment
+// as leading assignments so it can be pasted into a shell.
+func echoCommand(w io.Writer, cfg execConfig, args []string) {
+	words := make([]string, 0, len(cfg.env)+len(args)+1)
+	for _, kv := range cfg.env {
+		key, value, _ := strings.Cut(kv, "=")
+		words = append(words, key+"="+shellQuote(value))
+	}
+	words = append(words, shellQuote(cfg.binary()))
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	_, _ = fmt.Fprintf(w, "+ %s\n", strings.Join(words, " "))
+}
+
+// shellSafe matches words a POSIX shell reads literally.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote returns s as a single POSIX shell word, single-quoting it
+// unless it is made only of characters the shell reads literally.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"get":              "get",
+		"--context=prod":   "--context=prod",
+		"":                 "''",
+		"app in (web,api)": "'app in (web,api)'",
+		"it's":             `'it'\''s'`,
+		"$HOME":            "'$HOME'",
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEcho_PrintsCommandAndRunsIt(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "pod/web\n"}})
+	out, errOut, err := runCmd(t, "--echo", "--env", "HTTPS_PROXY=http://proxy:3128", "prod-us-east", "get", "pods", "-l", "app in (web)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "+ HTTPS_PROXY=http://proxy:3128 kubectl --context prod-us-east get pods -l 'app in (web)'\n"
+	if errOut != want {
+		t.Errorf("want echoed command %q, got %q", want, errOut)
+	}
+	if fake.total != 1 || !strings.Contains(out, "pod/web") {
+		t.Errorf("expected the command to still run, got %d call(s) and output %q", fake.total, out)
+	}
+}
+
+func TestEcho_Off(t *testing.T) {
+	useFakeKubectl(t)
+	if _, errOut, _ := runCmd(t, "--parallel", "prod", "get", "pods"); strings.Contains(errOut, "+ kubectl") {
+		t.Errorf("expected no echo without --echo, got %q", errOut)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/be0x74a/kubectl-xctx/xctx"
+)
+
+// The CLI reports failures with the library's error types.
+type (
+	ContextError = xctx.ContextError
+	MultiError   = xctx.MultiError
+)
+
+// The error categories main maps to exit codes.
+var (
+	ErrInvalidPattern  = xctx.ErrInvalidPattern
+	ErrExecutionFailed = xctx.ErrExecutionFailed
+	// ErrNoMatch is returned when no contexts match and --fail-on-empty is set.
+	ErrNoMatch = errors.New("no contexts matched")
+	// ErrUsage is matched by errors for invalid flags, arguments and flag
+	// combinations.
+	ErrUsage = errors.New("usage error")
+)
+
+// Exit codes for the error categories. Errors outside them exit 1, like
+// failed contexts.
+const (
+	exitFailed      = 1
+	exitNoMatch     = 4
+	exitUsage       = 64 // EX_USAGE from sysexits.h
+	exitInterrupted = 130
+)
+
+// exitStatus returns the process exit code for err.
+func exitStatus(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.Is(err, ErrInvalidPattern), errors.Is(err, ErrUsage):
+		return exitUsage
+	case errors.Is(err, ErrNoMatch):
+		return exitNoMatch
+	}
+	return exitFailed
+}
+
+// usageError wraps err so it matches ErrUsage, keeping its message.
+type usageError struct{ err error }
+
+func (e *usageError) Error() string   { return e.err.Error() }
+func (e *usageError) Unwrap() []error { return []error{e.err, ErrUsage} }
+
+// asUsage returns err marked as a usage error, or nil.
+func asUsage(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &usageError{err: err}
+}
+
+// newMultiError collects the errors of the failed results.
+func newMultiError(failed []result) *MultiError {
+	m := &MultiError{Errors: make([]*ContextError, len(failed))}
+	for i, r := range failed {
+		m.Errors[i] = &ContextError{Context: r.ctxName, Err: r.err}
+	}
+	return m
+}
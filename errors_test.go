@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failedContexts returns the context names carried by err's MultiError.
+func failedContexts(t *testing.T, err error) []string {
+	t.Helper()
+	var m *MultiError
+	if !errors.As(err, &m) {
+		t.Fatalf("expected a *MultiError, got %T: %v", err, err)
+	}
+	var names []string
+	for _, e := range m.Unwrap() {
+		var ce *ContextError
+		if !errors.As(e, &ce) {
+			t.Fatalf("expected a *ContextError, got %T", e)
+		}
+		names = append(names, ce.Context)
+	}
+	return names
+}
+
+// --- MultiError ---
+
+func TestMultiError_Sequential(t *testing.T) {
+	useFailingKubectl(t)
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"prod-us-east", "staging-us"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-us-east,staging-us" {
+		t.Errorf("want both contexts in the error, got %q", got)
+	}
+	if err.Error() != "2 context(s) failed" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestMultiError_ParallelUnwrapsUnderlyingErrors(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"staging-us": {err: context.DeadlineExceeded}})
+	var out, errOut strings.Builder
+	err := runParallel(context.Background(), []string{"prod-us-east", "staging-us", "dev-local"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+	if got := strings.Join(failedContexts(t, err), ","); got != "staging-us" {
+		t.Errorf("want only staging-us in the error, got %q", got)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected errors.Is to reach the context's error, got %v", err)
+	}
+}
+
+func TestMultiError_WrappedWhenStopped(t *testing.T) {
+	useFailingKubectl(t)
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"prod-us-east", "staging-us"}, []string{"get", "pods"}, &options{failFast: true}, &out, &errOut)
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-us-east" {
+		t.Errorf("want the first failure only, got %q", got)
+	}
+	if want := `stopped after failure in context "prod-us-east" (1 context(s) failed)`; err.Error() != want {
+		t.Errorf("want %q, got %q", want, err.Error())
+	}
+}
+
+// --- exit codes ---
+
+func TestExitStatus_PerCategory(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		sentinel error
+		want     int
+	}{
+		{"invalid pattern", []string{"prod[", "get", "pods"}, ErrInvalidPattern, exitUsage},
+		{"no match", []string{"nomatch", "get", "pods"}, nil, 0},
+		{"no match with --fail-on-empty", []string{"--fail-on-empty", "nomatch", "get", "pods"}, ErrNoMatch, exitNoMatch},
+		{"execution failure", []string{"prod", "get", "pods"}, ErrExecutionFailed, exitFailed},
+		{"stopped by --fail-fast", []string{"--fail-fast", "prod", "get", "pods"}, ErrExecutionFailed, exitFailed},
+		{"unknown flag", []string{"--no-such-flag", "prod", "get", "pods"}, ErrUsage, exitUsage},
+		{"bad flag value", []string{"--timeout", "soon", "prod", "get", "pods"}, ErrUsage, exitUsage},
+		{"conflicting flags", []string{"--fail-fast", "--max-failures", "2", "prod", "get", "pods"}, ErrUsage, exitUsage},
+		{"invalid flag combination", []string{"--rate", "5", "prod", "get", "pods"}, ErrUsage, exitUsage},
+		{"missing pattern", nil, ErrUsage, exitUsage},
+		{"--stdin with pattern from stdin", []string{"--stdin", "-", "apply", "-f", "-"}, ErrUsage, exitUsage},
+		{"--tail without logs", []string{"--tail", "prod", "get", "pods"}, ErrUsage, exitUsage},
+		// Flags naming a file that cannot be read are not usage errors.
+		{"missing --contexts-file", []string{"--contexts-file", "/nonexistent/contexts", "get", "pods"}, nil, exitFailed},
+		{"missing --tags-file", []string{"--tags-file", "/nonexistent/tags", "--tag", "web", "get", "pods"}, nil, exitFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useFailingKubectl(t)
+			_, _, err := runCmd(t, c.args...)
+			if c.sentinel != nil && !errors.Is(err, c.sentinel) {
+				t.Errorf("expected errors.Is(%v, %v)", err, c.sentinel)
+			}
+			if got := exitStatus(err); got != c.want {
+				t.Errorf("want exit code %d, got %d (err: %v)", c.want, got, err)
+			}
+		})
+	}
+}
+
+func TestExitStatus_Interrupted(t *testing.T) {
+	if got := exitStatus(errInterrupted); got != exitInterrupted {
+		t.Errorf("want exit code %d, got %d", exitInterrupted, got)
+	}
+}
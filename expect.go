@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/be0x74a/kubectl-xctx/xctx"
+)
+
+// errExpectMismatch marks a context whose kubectl command succeeded but whose
+// output did not satisfy --expect or --expect-regex.
+var errExpectMismatch = xctx.ErrOutputMismatch
+
+// checkExpect returns an error wrapping errExpectMismatch when stdout does
+// not satisfy the configured expectations, or nil when it does.
+func checkExpect(stdout []byte, opts *options) error {
+	if opts.expect != "" && !strings.Contains(string(stdout), opts.expect) {
+		return fmt.Errorf("%w --expect %q", errExpectMismatch, opts.expect)
+	}
+	if opts.expectRegex != nil && !opts.expectRegex.Match(stdout) {
+		return fmt.Errorf("%w --expect-regex %q", errExpectMismatch, strings.TrimPrefix(opts.expectRegex.String(), "(?m)"))
+	}
+	return nil
+}
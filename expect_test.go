@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useImageKubectl installs a mock reporting a different image per context,
+// with staging-us unable to run the command at all.
+func useImageKubectl(t *testing.T) {
+	t.Helper()
+	useContextKubectl(t, map[string]fakeResponse{
+		"staging-us": {err: errors.New("connection refused")},
+		"dev-local":  {stdout: "nginx:1.25\n"},
+		"":           {stdout: "nginx:1.27\n"},
+	})
+}
+
+// --- --expect ---
+
+func TestExpect_AllMatch(t *testing.T) {
+	useImageKubectl(t)
+	_, errOut, err := runCmd(t, "--expect", "nginx:1.27", "prod", "get", "deploy", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v (stderr %q)", err, errOut)
+	}
+}
+
+func TestExpect_MismatchFailsContext(t *testing.T) {
+	useImageKubectl(t)
+	out, errOut, err := runCmd(t, "--expect", "nginx:1.27", ".", "get", "deploy", "web")
+	want := "2 context(s) failed; output did not match in: dev-local; failed to run in: staging-us"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+	if !strings.Contains(errOut, `[xctx] context "dev-local" failed: output did not match --expect "nginx:1.27"`) {
+		t.Errorf("expected mismatch to be reported, got stderr %q", errOut)
+	}
+	// The mismatching output is still shown so the difference is visible.
+	if !strings.Contains(out, "nginx:1.25") {
+		t.Errorf("expected dev-local output to be printed, got %q", out)
+	}
+}
+
+func TestExpectRegex_Parallel(t *testing.T) {
+	useImageKubectl(t)
+	_, _, err := runCmd(t, "--parallel", "--expect-regex", `^nginx:1\.2[67]$`, "prod|dev", "get", "deploy", "web")
+	want := "1 context(s) failed; output did not match in: dev-local"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+func TestExpectRegex_Invalid(t *testing.T) {
+	useImageKubectl(t)
+	if _, _, err := runCmd(t, "--expect-regex", "(", ".", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --expect-regex") {
+		t.Errorf("expected invalid --expect-regex error, got %v", err)
+	}
+}
+
+// --- --fail-on-stderr ---
+
+func TestFailOnStderr(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-eu-west": {stdout: "configured\n", stderr: "Warning: resource is deprecated\n"},
+		"":             {stdout: "configured\n"},
+	})
+	if _, _, err := runCmd(t, "prod", "apply", "-f", "app.yaml"); err != nil {
+		t.Errorf("expected stderr alone not to fail the run, got %v", err)
+	}
+
+	_, errOut, err := runCmd(t, "--fail-on-stderr", "prod", "apply", "-f", "app.yaml")
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-eu-west" {
+		t.Errorf("want prod-eu-west to fail, got %q", got)
+	}
+	if !strings.Contains(errOut, `[xctx] context "prod-eu-west" failed: kubectl wrote to stderr (--fail-on-stderr)`) {
+		t.Errorf("expected the stderr failure to be explained, got %q", errOut)
+	}
+}
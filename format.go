@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"text/template"
+)
+
+// formatData is the value each --format template is executed with.
+type formatData struct {
+	Context     string
+	RealContext string
+	Server      string
+	Stdout      string
+	Stderr      string
+	Err         string
+	ExitCode    int
+	Index       int
+	Total       int
+}
+
+// parseFormat parses a template given with flag (--format or --format-file).
+// It also executes the template once against empty data so that references
+// to unknown fields fail before any context runs rather than halfway through
+// a run.
+func parseFormat(flag, text string) (*template.Template, error) {
+	tmpl, err := template.New("format").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s template: %w", flag, err)
+	}
+	if err := tmpl.Execute(io.Discard, formatData{}); err != nil {
+		return nil, fmt.Errorf("invalid --%s template: %w", flag, err)
+	}
+	return tmpl, nil
+}
+
+// readFormatFile reads and parses the --format-file template at path.
+func readFormatFile(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path) //nolint:gosec // path is the user's own flag value
+	if err != nil {
+		return nil, fmt.Errorf("reading --format-file: %w", err)
+	}
+	return parseFormat("format-file", string(text))
+}
+
+// printFormatted renders r with the --format template in place of the
+// default header/output layout.
+func printFormatted(r result, opts *options, out, errOut io.Writer) {
+	data := formatData{
+		Context:     displayName(r.ctxName, opts),
+		RealContext: r.ctxName,
+		Server:      opts.contextInfo[r.ctxName].server,
+		Stdout:      string(r.stdout),
+		Stderr:      string(r.stderr),
+		ExitCode:    exitCode(r.err),
+		Index:       r.index + 1,
+		Total:       r.total,
+	}
+	if r.err != nil {
+		data.Err = r.err.Error()
+	}
+	if err := opts.format.Execute(out, data); err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q: format error: %v\n", r.ctxName, err)
+	}
+}
+
+// exitCode returns kubectl's exit status for err: 0 on success, the process
+// exit code when kubectl ran and failed, and -1 when it could not be run.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
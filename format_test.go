@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- parseFormat ---
+
+func TestParseFormat_SyntaxError(t *testing.T) {
+	if _, err := parseFormat("format", "{{.Context"); err == nil {
+		t.Fatal("expected parse error, got nil")
+	}
+}
+
+func TestParseFormat_UnknownField(t *testing.T) {
+	if _, err := parseFormat("format", "{{.Cluster}}"); err == nil {
+		t.Fatal("expected error for unknown field, got nil")
+	}
+}
+
+// --- printFormatted ---
+
+func TestPrintFormatted_ContextAndExitCode(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"ctx-b": {stderr: "boom\n", err: errors.New("connection refused")},
+		"":      {stdout: "ok\n"},
+	})
+	tmpl, err := parseFormat("format", "[{{.Index}}/{{.Total}}] {{.Context}} exit={{.ExitCode}}{{if .Err}} err={{.Err}}{{end}}\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out, errOut strings.Builder
+	_ = runSequential(context.Background(), []string{"ctx-a", "ctx-b"}, []string{"get", "pods"}, &options{header: "### Context: {context}", format: tmpl}, &out, &errOut)
+
+	want := "[1/2] ctx-a exit=0\n[2/2] ctx-b exit=-1 err=connection refused\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\nwant %q\ngot  %q", want, out.String())
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("expected template to replace default stderr rendering, got: %q", errOut.String())
+	}
+}
+
+// --- --format-file ---
+
+func TestFormatFile_RendersTemplate(t *testing.T) {
+	useFakeKubectl(t)
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Index}}/{{.Total}} {{.Context}}: {{.Stdout}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := runCmd(t, "--format-file", path, "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1/2 prod-us-east: result from prod-us-east\n2/2 prod-eu-west: result from prod-eu-west\n"
+	if out != want {
+		t.Errorf("unexpected output:\nwant %q\ngot  %q", want, out)
+	}
+}
+
+func TestFormatFile_Errors(t *testing.T) {
+	useFakeKubectl(t)
+	bad := filepath.Join(t.TempDir(), "bad.tmpl")
+	if err := os.WriteFile(bad, []byte("{{.Context"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		filepath.Join(t.TempDir(), "missing.tmpl"): "reading --format-file: ",
+		bad: "invalid --format-file template: ",
+	} {
+		_, _, err := runCmd(t, "--format-file", path, "prod", "get", "pods")
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: want error starting %q, got %v", filepath.Base(path), want, err)
+		}
+	}
+}
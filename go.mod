@@ -2,9 +2,16 @@ module github.com/be0x74a/kubectl-xctx
 
 go 1.23
 
-require github.com/spf13/cobra v1.10.2
+require (
+	github.com/itchyny/gojq v0.12.17
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/spf13/cobra v1.10.2
+	github.com/spf13/pflag v1.0.9
+	golang.org/x/time v0.10.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.9 // indirect
+	github.com/itchyny/timefmt-go v0.1.6 // indirect
 )
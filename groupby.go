@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// grouping extracts the --group-by key from a context name, either with the
+// first capture group of a regex or as the text before a delimiter. For
+// --group-by-namespace the key is the context's default namespace instead.
+type grouping struct {
+	re          *regexp.Regexp
+	delim       string
+	byNamespace bool
+	namespaces  map[string]string // context name to default namespace, once loaded
+}
+
+// parseGroupBy parses a --group-by value. A regex with a capture group is
+// used as such; anything else is taken as a literal delimiter.
+func parseGroupBy(value string) (*grouping, error) {
+	if value == "" {
+		return nil, fmt.Errorf("invalid --group-by: must not be empty")
+	}
+	if re, err := regexp.Compile(value); err == nil && re.NumSubexp() > 0 {
+		return &grouping{re: re}, nil
+	}
+	return &grouping{delim: value}, nil
+}
+
+// key returns the group of ctxName. A name the regex does not match, or that
+// lacks the delimiter, forms a group of its own.
+func (g *grouping) key(ctxName string) string {
+	if g.byNamespace {
+		if ns := g.namespaces[ctxName]; ns != "" {
+			return ns
+		}
+		return "default"
+	}
+	if g.re != nil {
+		if m := g.re.FindStringSubmatch(ctxName); m != nil {
+			return m[1]
+		}
+		return ctxName
+	}
+	key, _, _ := strings.Cut(ctxName, g.delim)
+	return key
+}
+
+// parseGroupCmds parses "groupKey=kubectl args" --group-cmd values into the
+// args, split on spaces, to run in each group.
+func parseGroupCmds(values []string) (map[string][]string, error) {
+	cmds := make(map[string][]string, len(values))
+	for _, v := range values {
+		key, cmdline, ok := strings.Cut(v, "=")
+		args := strings.Fields(cmdline)
+		if !ok || key == "" || len(args) == 0 {
+			return nil, fmt.Errorf("invalid --group-cmd %q: expected groupKey=kubectl args", v)
+		}
+		cmds[key] = args
+	}
+	return cmds, nil
+}
+
+// argsFor returns the kubectl args to run in ctxName: its group's
+// --group-cmd if one is given, otherwise args.
+func argsFor(ctxName string, args []string, opts *options) []string {
+	if opts.groupBy != nil {
+		if groupArgs, ok := opts.groupCmds[opts.groupBy.key(ctxName)]; ok {
+			return groupArgs
+		}
+	}
+	return args
+}
+
+// allCommands returns every kubectl command a run may use: args, then each
+// --group-cmd in group key order.
+func allCommands(args []string, opts *options) [][]string {
+	cmds := [][]string{args}
+	keys := make([]string, 0, len(opts.groupCmds))
+	for key := range opts.groupCmds {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmds = append(cmds, opts.groupCmds[key])
+	}
+	return cmds
+}
+
+// loadNamespaces looks up each context's default namespace for
+// --group-by-namespace, sharing the kubeconfig view with --explain.
+func loadNamespaces(g *grouping, opts *options) error {
+	if err := ensureContextInfo(opts); err != nil {
+		return err
+	}
+	g.namespaces = make(map[string]string, len(opts.contextInfo))
+	for name, info := range opts.contextInfo {
+		g.namespaces[name] = info.defaultNamespace()
+	}
+	return nil
+}
+
+// groupRanks numbers the groups of names in order of first appearance.
+func groupRanks(names []string, g *grouping) map[string]int {
+	ranks := map[string]int{}
+	for _, name := range names {
+		k := g.key(name)
+		if _, ok := ranks[k]; !ok {
+			ranks[k] = len(ranks)
+		}
+	}
+	return ranks
+}
+
+// groupContexts reorders contexts in place so that each group is contiguous.
+// Groups keep the order of their first member, and members keep their
+// relative order.
+func groupContexts(contexts []string, g *grouping) {
+	ranks := groupRanks(contexts, g)
+	sort.SliceStable(contexts, func(i, j int) bool {
+		return ranks[g.key(contexts[i])] < ranks[g.key(contexts[j])]
+	})
+}
+
+// groupResults is groupContexts for results that have already been sorted,
+// so the sort order still applies within each group.
+func groupResults(results []result, g *grouping) {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.ctxName
+	}
+	ranks := groupRanks(names, g)
+	sort.SliceStable(results, func(i, j int) bool {
+		return ranks[g.key(results[i].ctxName)] < ranks[g.key(results[j].ctxName)]
+	})
+}
+
+// groupHeaders prints a "<group>:" line, or "# namespace: <ns>" for
+// --group-by-namespace, before the first result of each group.
+type groupHeaders struct {
+	started bool
+	last    string
+}
+
+func (h *groupHeaders) print(r result, opts *options, out io.Writer) {
+	if opts.groupBy == nil {
+		return
+	}
+	k := opts.groupBy.key(r.ctxName)
+	if h.started && k == h.last {
+		return
+	}
+	h.started, h.last = true, k
+	if opts.groupBy.byNamespace {
+		_, _ = fmt.Fprintf(out, "# namespace: %s\n", k)
+		return
+	}
+	_, _ = fmt.Fprintf(out, "%s:\n", k)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// --- parseGroupBy ---
+
+func TestParseGroupBy_RegexOrDelimiter(t *testing.T) {
+	cases := []struct {
+		value, ctxName, want string
+	}{
+		{"-", "prod-us-east", "prod"},
+		{"-", "minikube", "minikube"},
+		{`-(us|eu)-`, "prod-eu-west", "eu"},
+		{`-(us|eu)-`, "dev-local", "dev-local"},
+	}
+	for _, c := range cases {
+		g, err := parseGroupBy(c.value)
+		if err != nil {
+			t.Fatalf("parseGroupBy(%q): unexpected error: %v", c.value, err)
+		}
+		if got := g.key(c.ctxName); got != c.want {
+			t.Errorf("--group-by %q: key(%q) = %q, want %q", c.value, c.ctxName, got, c.want)
+		}
+	}
+}
+
+// --- --group-by ---
+
+func TestGroupBy_DelimiterGroupsByFirstSegment(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--group-by", "-", "--header", "{context}", ".", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "prod:\n" +
+		"prod-us-east\nresult from prod-us-east\n\n" +
+		"prod-eu-west\nresult from prod-eu-west\n\n" +
+		"staging:\n" +
+		"staging-us\nresult from staging-us\n\n" +
+		"dev:\n" +
+		"dev-local\nresult from dev-local\n\n"
+	if out != want {
+		t.Errorf("unexpected grouped output:\ngot:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestGroupBy_ParallelKeepsSortWithinGroups(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--parallel", "--sort-output", "name", "--group-by", `^(\w+)-`, "--prefix-lines", ".", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		name, _, _ := strings.Cut(line, "\t")
+		got = append(got, name)
+	}
+	want := "dev: dev-local prod: prod-eu-west prod-us-east staging: staging-us"
+	if strings.Join(got, " ") != want {
+		t.Errorf("want %q, got %q", want, strings.Join(got, " "))
+	}
+}
+
+func TestGroupBy_ListIsGroupedWithoutHeaders(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--group-by", "-", "--context", "prod-us-east", "--context", "dev-local", "--context", "prod-eu-west", "--list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "prod-us-east\nprod-eu-west\ndev-local\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+// --- --group-by-namespace ---
+
+func TestGroupByNamespace(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{
+		"config view": {stdout: "context\tstaging-us\tstaging\t\n" +
+			"context\tprod-us-east\tus-east\tkube-system\n" +
+			"context\tdev-local\tdev\tdefault\n"},
+		"": {stdout: "result from {context}\n"},
+	})
+	out, _, err := runCmd(t, "--group-by-namespace", "--header", "{context}", "--context", "staging-us", "--context", "prod-us-east", "--context", "dev-local", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "# namespace: default\n" +
+		"staging-us\nresult from staging-us\n\n" +
+		"dev-local\nresult from dev-local\n\n" +
+		"# namespace: kube-system\n" +
+		"prod-us-east\nresult from prod-us-east\n\n"
+	if out != want {
+		t.Errorf("unexpected grouped output:\ngot:\n%s\nwant:\n%s", out, want)
+	}
+	if fake.calls["config view"] != 1 {
+		t.Errorf("expected a single kubeconfig view lookup, got %d", fake.calls["config view"])
+	}
+}
+
+// --- --group-cmd ---
+
+func TestGroupCmd_RunsPerGroupArgs(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	_, _, err := runCmd(t, "--group-by", "-", "--group-cmd", "prod=describe nodes", "prod|dev", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"kubectl --context prod-us-east describe nodes",
+		"kubectl --context prod-eu-west describe nodes",
+		"kubectl --context dev-local get pods",
+	} {
+		if !slices.Contains(fake.commands, want) {
+			t.Errorf("want %q to run, got %q", want, fake.commands)
+		}
+	}
+}
+
+func TestGroupCmd_Errors(t *testing.T) {
+	useFakeKubectl(t)
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--group-cmd", "prod=get nodes", ".", "get", "pods"}, "--group-cmd requires --group-by or --group-by-namespace"},
+		{[]string{"--group-by", "-", "--group-cmd", "prod=", ".", "get", "pods"}, `invalid --group-cmd "prod="`},
+		{[]string{"--group-by", "-", "--group-cmd", "prod=delete pods --all", ".", "get", "pods"}, "refusing to run"},
+	}
+	for _, c := range cases {
+		_, _, err := runCmd(t, c.args...)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: want error containing %q, got %v", c.args, c.want, err)
+		}
+	}
+}
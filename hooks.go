@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// hookRunner runs a --before-each or --after-each command through the OS
+// shell, writing its output to out, the run's stderr, so stdout stays
+// kubectl's. Overridable in tests.
+var hookRunner = func(ctx context.Context, command string, out io.Writer) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// runHook runs the hook command for ctxName, with {context} replaced by the
+// context name, quoted so the shell reads it as a single word.
+func runHook(ctx context.Context, flag, command, ctxName string, opts *options) error {
+	if err := hookRunner(ctx, strings.ReplaceAll(command, "{context}", hookQuote(ctxName)), opts.hookOut); err != nil {
+		return fmt.Errorf("--%s command failed: %w", flag, err)
+	}
+	return nil
+}
+
+// hookQuote quotes s for the shell hookRunner uses: cmd on Windows, where
+// double quotes keep &, | and the like literal, and a POSIX shell elsewhere.
+func hookQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return shellQuote(s)
+}
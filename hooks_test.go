@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordHooks replaces hookRunner and kubectlRunner with fakes that log
+// every hook command and kubectl call, in order. Hooks containing "fail"
+// exit non-zero.
+func recordHooks(t *testing.T) *[]string {
+	t.Helper()
+	var events []string
+	orig := hookRunner
+	hookRunner = func(_ context.Context, command string, _ io.Writer) error {
+		events = append(events, "hook: "+command)
+		if strings.Contains(command, "fail") {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	t.Cleanup(func() { hookRunner = orig })
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		events = append(events, "kubectl: "+args[1])
+		return nil, nil, nil
+	})
+	return &events
+}
+
+// --- --before-each / --after-each ---
+
+func TestHooks_RunAroundEachContext(t *testing.T) {
+	events := recordHooks(t)
+	_, _, err := runCmd(t, "--before-each", "aws sso login --profile {context}", "--after-each", "echo done {context}", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"hook: aws sso login --profile prod-us-east",
+		"kubectl: prod-us-east",
+		"hook: echo done prod-us-east",
+		"hook: aws sso login --profile prod-eu-west",
+		"kubectl: prod-eu-west",
+		"hook: echo done prod-eu-west",
+	}
+	if strings.Join(*events, "|") != strings.Join(want, "|") {
+		t.Errorf("want events %q, got %q", want, *events)
+	}
+}
+
+func TestHooks_FailedBeforeEachSkipsContext(t *testing.T) {
+	events := recordHooks(t)
+	_, errOut, err := runCmd(t, "--before-each", "login {context}", "--context", "prod-us-east", "--context", "fail-me", "get", "pods")
+	if got := strings.Join(failedContexts(t, err), ","); got != "fail-me" {
+		t.Errorf("want fail-me to fail, got %q", got)
+	}
+	for _, e := range *events {
+		if e == "kubectl: fail-me" {
+			t.Error("expected kubectl not to run after a failed --before-each")
+		}
+	}
+	if !strings.Contains(errOut, `[xctx] context "fail-me" failed: --before-each command failed: exit status 1`) {
+		t.Errorf("expected the hook failure to be reported, got %q", errOut)
+	}
+}
+
+func TestHooks_ContextNameIsQuoted(t *testing.T) {
+	events := recordHooks(t)
+	_, _, err := runCmd(t, "--before-each", "login {context}", "--context", "prod; touch pwned", "--context", "it's-prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"hook: login 'prod; touch pwned'",
+		"kubectl: prod; touch pwned",
+		`hook: login 'it'\''s-prod'`,
+		"kubectl: it's-prod",
+	}
+	if strings.Join(*events, "|") != strings.Join(want, "|") {
+		t.Errorf("want events %q, got %q", want, *events)
+	}
+}
+
+func TestHooks_FailedAfterEachFailsContext(t *testing.T) {
+	recordHooks(t)
+	_, _, err := runCmd(t, "--after-each", "fail {context}", "staging", "get", "pods")
+	if err == nil || err.Error() != "1 context(s) failed" {
+		t.Errorf("expected the after-each failure to fail the context, got %v", err)
+	}
+}
+
+func TestHooks_AfterEachRunsAfterTimeout(t *testing.T) {
+	var hookErr error
+	orig := hookRunner
+	hookRunner = func(ctx context.Context, command string, out io.Writer) error {
+		hookErr = ctx.Err()
+		_, _ = io.WriteString(out, "hook: "+command+"\n")
+		return nil
+	}
+	t.Cleanup(func() { hookRunner = orig })
+	useContextKubectl(t, map[string]fakeResponse{"": {delay: time.Hour, err: errors.New("signal: killed")}})
+	_, errOut, _ := runCmd(t, "--timeout", "10ms", "--after-each", "cleanup {context}", "staging", "get", "pods")
+	if hookErr != nil {
+		t.Errorf("expected the after-each hook to run with a live context, got %v", hookErr)
+	}
+	if !strings.Contains(errOut, "hook: cleanup staging-us\n") {
+		t.Errorf("expected the hook's output on the run's stderr, got %q", errOut)
+	}
+	if !strings.Contains(errOut, `[xctx] context "staging-us" timed out`) {
+		t.Errorf("expected the timeout reported, got %q", errOut)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// contextSelector lets the user pick from contexts for --interactive.
+// Overridable in tests.
+var contextSelector = promptContexts
+
+// promptContexts lists contexts on errOut, numbered, and reads the user's
+// choice from in, which must be a terminal.
+func promptContexts(contexts []string, in io.Reader, errOut io.Writer) ([]string, error) {
+	if f, ok := in.(*os.File); !ok || !isTerminal(f) {
+		return nil, errors.New("--interactive needs a terminal on stdin; give a context pattern instead")
+	}
+	for i, c := range contexts {
+		_, _ = fmt.Fprintf(errOut, "%3d) %s\n", i+1, c)
+	}
+	_, _ = fmt.Fprint(errOut, "[xctx] contexts to run in (numbers and ranges like \"1 3-5\", or \"all\"): ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(errOut)
+		return nil, errors.New("no contexts selected")
+	}
+	return parseSelection(answer, contexts)
+}
+
+// parseSelection returns the contexts an answer to promptContexts picks:
+// 1-based numbers and ranges separated by spaces or commas, or "all". Each
+// context is picked at most once, in the order given.
+func parseSelection(answer string, contexts []string) ([]string, error) {
+	fields := strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r' })
+	if len(fields) == 1 && fields[0] == "all" {
+		return contexts, nil
+	}
+	var picked []string
+	seen := map[int]bool{}
+	for _, f := range fields {
+		lo, hi, isRange := strings.Cut(f, "-")
+		if !isRange {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from < 1 || to > len(contexts) || from > to {
+			return nil, fmt.Errorf("invalid selection %q: expected numbers from 1 to %d", f, len(contexts))
+		}
+		for i := from; i <= to; i++ {
+			if !seen[i] {
+				seen[i] = true
+				picked = append(picked, contexts[i-1])
+			}
+		}
+	}
+	if len(picked) == 0 {
+		return nil, errors.New("no contexts selected")
+	}
+	return picked, nil
+}
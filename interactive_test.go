@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeSelector replaces contextSelector with one that picks the contexts at
+// the given 0-based positions and records what it was offered.
+func fakeSelector(t *testing.T, picks ...int) *[]string {
+	t.Helper()
+	var offered []string
+	orig := contextSelector
+	contextSelector = func(contexts []string, _ io.Reader, _ io.Writer) ([]string, error) {
+		offered = contexts
+		var chosen []string
+		for _, i := range picks {
+			chosen = append(chosen, contexts[i])
+		}
+		return chosen, nil
+	}
+	t.Cleanup(func() { contextSelector = orig })
+	return &offered
+}
+
+func TestInteractive_RunsSelectedContexts(t *testing.T) {
+	useFakeKubectl(t)
+	offered := fakeSelector(t, 3, 1)
+	out, _, err := runCmd(t, "-I", "--header", "", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*offered) != 4 {
+		t.Errorf("expected every context to be offered, got %q", *offered)
+	}
+	if out != "result from dev-local\nresult from prod-eu-west\n" {
+		t.Errorf("expected only the chosen contexts to run, got %q", out)
+	}
+}
+
+func TestInteractive_NeedsTerminal(t *testing.T) {
+	_, err := promptContexts([]string{"a"}, strings.NewReader("1\n"), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "needs a terminal") {
+		t.Errorf("expected a non-terminal stdin to be rejected, got %v", err)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	contexts := []string{"a", "b", "c", "d", "e"}
+	for _, tc := range []struct {
+		answer string
+		want   string
+	}{
+		{"2\n", "b"},
+		{"4 1-2\n", "d,a,b"},
+		{"1,3-5,3", "a,c,d,e"},
+		{"all\n", "a,b,c,d,e"},
+		{"\n", "error"},
+		{"0", "error"},
+		{"6", "error"},
+		{"3-2", "error"},
+		{"b", "error"},
+	} {
+		picked, err := parseSelection(tc.answer, contexts)
+		got := strings.Join(picked, ",")
+		if err != nil {
+			got = "error"
+		}
+		if got != tc.want {
+			t.Errorf("parseSelection(%q) = %s, want %s", tc.answer, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// compileJQ parses and compiles a --jq expression.
+func compileJQ(expr string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --jq %q: %w", expr, err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --jq %q: %w", expr, err)
+	}
+	return code, nil
+}
+
+// applyJQ runs code over stdout parsed as JSON and returns each value it
+// produces as indented JSON, one after another like jq.
+func applyJQ(code *gojq.Code, stdout []byte) ([]byte, error) {
+	var input any
+	if err := json.Unmarshal(stdout, &input); err != nil {
+		return nil, fmt.Errorf("--jq: output is not JSON: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, isErr := v.(error); isErr {
+			return nil, fmt.Errorf("--jq: %w", err)
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, fmt.Errorf("--jq: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// podLists answers with JSON pod lists of two, zero and one items for
+// prod-us-east, prod-eu-west and staging-us, and plain text for dev-local.
+var podLists = map[string]fakeResponse{
+	"prod-us-east": {stdout: `{"items":[{"metadata":{"name":"web"}},{"metadata":{"name":"api"}}]}`},
+	"prod-eu-west": {stdout: `{"items":[]}`},
+	"staging-us":   {stdout: `{"items":[{"metadata":{"name":"web"}}]}`},
+	"":             {stdout: "No resources found\n"},
+}
+
+func TestJQ_ItemsLength(t *testing.T) {
+	useContextKubectl(t, podLists)
+	out, errOut, err := runCmd(t, "--jq", ".items | length", "--header", "{context}", ".", "get", "pods", "-o", "json")
+	if got := strings.Join(failedContexts(t, err), ","); got != "dev-local" {
+		t.Errorf("want only dev-local to fail, got %q", got)
+	}
+	for _, want := range []string{"prod-us-east\n2\n", "prod-eu-west\n0\n", "staging-us\n1\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+	if !strings.Contains(errOut, `context "dev-local" failed: --jq: output is not JSON`) {
+		t.Errorf("expected the non-JSON output to be reported, got %q", errOut)
+	}
+}
+
+func TestJQ_MultipleResults(t *testing.T) {
+	useContextKubectl(t, podLists)
+	out, _, err := runCmd(t, "--jq", ".items[].metadata.name", "--header", "", "prod-us-east", "get", "pods", "-o", "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "\"web\"\n\"api\"\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestJQ_FilterErrorFailsOnlyThatContext(t *testing.T) {
+	useContextKubectl(t, podLists)
+	_, errOut, err := runCmd(t, "--jq", ".items[0].metadata.name | ascii_downcase", "prod", "get", "pods", "-o", "json")
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-eu-west" {
+		t.Errorf("want only prod-eu-west to fail, got %q", got)
+	}
+	if !strings.Contains(errOut, `context "prod-eu-west" failed: --jq:`) {
+		t.Errorf("expected the filter error to be reported, got %q", errOut)
+	}
+}
+
+func TestJQ_InvalidExpression(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--jq", ".items |", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --jq") {
+		t.Errorf("expected invalid --jq error, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// contextInfo describes a context's entry in the merged kubeconfig.
+type contextInfo struct {
+	cluster   string
+	server    string
+	namespace string
+}
+
+// defaultNamespace returns the namespace kubectl uses in the context: the
+// one it sets, or "default".
+func (c contextInfo) defaultNamespace() string {
+	if c.namespace == "" {
+		return "default"
+	}
+	return c.namespace
+}
+
+// kubeconfigViewTemplate prints one tab-separated line per context and per
+// cluster, so a single "kubectl config view" call covers every context.
+const kubeconfigViewTemplate = `{range .contexts[*]}context{"\t"}{.name}{"\t"}{.context.cluster}{"\t"}{.context.namespace}{"\n"}{end}` +
+	`{range .clusters[*]}cluster{"\t"}{.name}{"\t"}{.cluster.server}{"\n"}{end}`
+
+// loadContextInfo returns kubeconfig details for every context, keyed by
+// context name.
+func loadContextInfo(cfg execConfig) (map[string]contextInfo, error) {
+	out, _, err := kubectlRunner(context.Background(), cfg, "config", "view", "-o", "jsonpath="+kubeconfigViewTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+
+	infos := map[string]contextInfo{}
+	servers := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "context":
+			info := contextInfo{cluster: fields[2]}
+			if len(fields) > 3 {
+				info.namespace = fields[3]
+			}
+			infos[fields[1]] = info
+		case "cluster":
+			servers[fields[1]] = fields[2]
+		}
+	}
+	for name, info := range infos {
+		info.server = servers[info.cluster]
+		infos[name] = info
+	}
+	return infos, nil
+}
+
+// matchingNamespaces returns the contexts, in kubeconfig order, whose default
+// namespace re matches, for --match-field namespace. A context that sets no
+// namespace is matched as "default", the namespace kubectl uses for it.
+func matchingNamespaces(re *regexp.Regexp, cfg execConfig) ([]string, error) {
+	all, err := listContexts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := loadContextInfo(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, name := range all {
+		if re.MatchString(infos[name].defaultNamespace()) {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
+
+// ensureContextInfo loads opts.contextInfo unless an earlier step already
+// did, so a run reads the kubeconfig view at most once.
+func ensureContextInfo(opts *options) error {
+	if opts.contextInfo != nil {
+		return nil
+	}
+	infos, err := loadContextInfo(opts.baseExec())
+	if err != nil {
+		return err
+	}
+	opts.contextInfo = infos
+	return nil
+}
+
+// dedupeByServer returns contexts with only the first context for each API
+// server kept, for --dedupe-by-server, listing the others on errOut.
+// Contexts whose server is unknown are all kept.
+func dedupeByServer(contexts []string, opts *options, errOut io.Writer) ([]string, error) {
+	if err := ensureContextInfo(opts); err != nil {
+		return nil, err
+	}
+	var kept []string
+	first := map[string]string{}
+	for _, name := range contexts {
+		server := opts.contextInfo[name].server
+		if server == "" {
+			kept = append(kept, name)
+			continue
+		}
+		if prev, ok := first[server]; ok {
+			_, _ = fmt.Fprintf(errOut, "[xctx] skipping context %q: same server as %q (%s)\n", name, prev, server)
+			continue
+		}
+		first[server] = name
+		kept = append(kept, name)
+	}
+	return kept, nil
+}
+
+// kubeconfigDirFiles returns the kubeconfig files in dir for
+// --kubeconfig-dir: the regular *.yaml and *.yml files, in name order.
+// Symlinks, subdirectories and other files are skipped.
+func kubeconfigDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading --kubeconfig-dir: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		switch filepath.Ext(e.Name()) {
+		case ".yaml", ".yml":
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.yaml or *.yml kubeconfig files in --kubeconfig-dir %q", dir)
+	}
+	return files, nil
+}
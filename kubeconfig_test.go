@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeKubeconfigView is "kubectl config view" output for kubeconfigViewTemplate.
+const fakeKubeconfigView = "context\tprod-us-east\tus-east\n" +
+	"context\tprod-eu-west\teu-west\n" +
+	"cluster\tus-east\thttps://us-east.example.com\n" +
+	"cluster\teu-west\thttps://eu-west.example.com\n"
+
+// withKubeconfigView answers "kubectl config view" with fakeKubeconfigView
+// and every command in a context with "result from <ctx>".
+var withKubeconfigView = map[string]fakeResponse{
+	"config view": {stdout: fakeKubeconfigView},
+	"":            {stdout: "result from {context}\n"},
+}
+
+// --- loadContextInfo ---
+
+func TestLoadContextInfo(t *testing.T) {
+	useContextKubectl(t, withKubeconfigView)
+	infos, err := loadContextInfo(execConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := infos["prod-eu-west"]; got.cluster != "eu-west" || got.server != "https://eu-west.example.com" {
+		t.Errorf("unexpected info for prod-eu-west: %+v", got)
+	}
+	if _, ok := infos["staging-us"]; ok {
+		t.Error("expected no info for a context missing from the view")
+	}
+}
+
+// --- explain ---
+
+func TestExplain_ServerPlaceholder(t *testing.T) {
+	useContextKubectl(t, withKubeconfigView)
+	out, _, err := runCmd(t, "--explain", "--header", "{context} -> {server}", "prod-us-east", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "prod-us-east -> https://us-east.example.com\n") {
+		t.Errorf("expected {server} resolved, got: %q", out)
+	}
+}
+
+func TestExplain_DefaultHeaderShowsServer(t *testing.T) {
+	useContextKubectl(t, withKubeconfigView)
+	out, _, err := runCmd(t, "--explain", "prod-eu-west", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "### Context: prod-eu-west (https://eu-west.example.com)\n") {
+		t.Errorf("expected server appended to the default header, got: %q", out)
+	}
+}
+
+func TestExplain_ViewLoadedOnce(t *testing.T) {
+	fake := useContextKubectl(t, withKubeconfigView)
+	if _, _, err := runCmd(t, "--explain", "prod", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.calls["config view"] != 1 {
+		t.Errorf("expected a single config view lookup, got %d", fake.calls["config view"])
+	}
+}
+
+// --- --match-field namespace ---
+
+// namespacedView is a kubeconfig view where the contexts default to
+// different namespaces, and dev-local sets none.
+const namespacedView = "context\tprod-us-east\tus-east\tkube-system\n" +
+	"context\tprod-eu-west\teu-west\tpayments\n" +
+	"context\tstaging-us\tstaging\tkube-system\n" +
+	"context\tdev-local\tdev\t\n"
+
+func TestMatchField_Namespace(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"config view": {stdout: namespacedView}})
+	for _, tc := range []struct {
+		pattern string
+		want    string
+	}{
+		{"kube-system", "prod-us-east staging-us"},
+		{"pay", "prod-eu-west"},
+		{"^default$", "dev-local"},
+	} {
+		out, _, err := runCmd(t, "--match-field", "namespace", "--list", tc.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(strings.Fields(out), " "); got != tc.want {
+			t.Errorf("pattern %q: want %q, got %q", tc.pattern, tc.want, got)
+		}
+	}
+}
+
+func TestMatchField_Invalid(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--match-field", "cluster", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --match-field") {
+		t.Errorf("expected invalid --match-field error, got %v", err)
+	}
+}
+
+// --- --dedupe-by-server ---
+
+func TestDedupeByServer(t *testing.T) {
+	view := "context\tprod-us-east\tus-east\t\n" +
+		"context\tprod-eu-west\teu-west\t\n" +
+		"context\tstaging-us\tus-east-admin\t\n" +
+		"cluster\tus-east\thttps://us-east.example.com\n" +
+		"cluster\tus-east-admin\thttps://us-east.example.com\n" +
+		"cluster\teu-west\thttps://eu-west.example.com\n"
+	fake := useContextKubectl(t, map[string]fakeResponse{"config view": {stdout: view}})
+	_, errOut, err := runCmd(t, "--dedupe-by-server", ".", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "kubectl --context prod-us-east get pods|kubectl --context prod-eu-west get pods|kubectl --context dev-local get pods"
+	if strings.Join(fake.commands, "|") != want {
+		t.Errorf("want %q to run, got %q", want, fake.commands)
+	}
+	if !strings.Contains(errOut, `[xctx] skipping context "staging-us": same server as "prod-us-east" (https://us-east.example.com)`) {
+		t.Errorf("expected the dropped duplicate to be listed, got %q", errOut)
+	}
+}
+
+// --- --kubeconfig-dir ---
+
+func TestKubeconfigDir_MergesYAMLFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.yml", "a.yaml", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("apiVersion: v1\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.yaml"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "a.yaml"), filepath.Join(dir, "link.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var envs []string
+	mockKubectlExec(t, func(_ context.Context, cfg execConfig, args ...string) ([]byte, []byte, error) {
+		mu.Lock()
+		envs = append(envs, strings.Join(cfg.env, ","))
+		mu.Unlock()
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		return nil, nil, nil
+	})
+	if _, _, err := runCmd(t, "--kubeconfig-dir", dir, "--parallel", "prod", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "KUBECONFIG=" + filepath.Join(dir, "a.yaml") + string(filepath.ListSeparator) + filepath.Join(dir, "b.yml")
+	if len(envs) != 3 {
+		t.Fatalf("expected a listing and two runs, got %d kubectl calls", len(envs))
+	}
+	for _, env := range envs {
+		if env != want {
+			t.Errorf("want env %q, got %q", want, env)
+		}
+	}
+}
+
+func TestKubeconfigDir_NoConfigFiles(t *testing.T) {
+	useFakeKubectl(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := runCmd(t, "--kubeconfig-dir", dir, "prod", "get", "pods")
+	if err == nil || !strings.Contains(err.Error(), "no *.yaml or *.yml kubeconfig files") {
+		t.Errorf("expected an error for a directory without kubeconfigs, got %v", err)
+	}
+}
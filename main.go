@@ -1,49 +1,282 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
+	"math/rand"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"text/template"
 	"time"
 
+	"github.com/itchyny/gojq"
 	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+
+	"github.com/be0x74a/kubectl-xctx/xctx"
 )
 
 // version is set via -ldflags at build time.
 var version = "dev"
 
+// execConfig carries per-invocation settings for kubectlRunner.
+type execConfig struct {
+	bin          string        // kubectl binary to run; empty means kubectl on PATH
+	env          []string      // KEY=VALUE pairs added to the inherited environment
+	stdoutLimit  int64         // stdout bytes to keep, plus one to detect overflow; 0 = all
+	contextCache *contextCache // consulted by listContexts; nil = always ask kubectl
+	stdin        []byte        // fed to kubectl's stdin when non-nil, for --stdin
+}
+
+// binary returns the kubectl binary cfg runs.
+func (cfg execConfig) binary() string {
+	if cfg.bin == "" {
+		return "kubectl"
+	}
+	return cfg.bin
+}
+
+// runner adapts kubectlRunner to the library's Runner for cfg.
+func (cfg execConfig) runner() xctx.Runner {
+	return func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+		return kubectlRunner(ctx, cfg, args...)
+	}
+}
+
+// kubectlCommand builds the kubectl command for args under cfg.
+func kubectlCommand(ctx context.Context, cfg execConfig, args []string) *exec.Cmd {
+	cmd := xctx.Command(ctx, cfg.bin, cfg.env, args)
+	if cfg.stdin != nil {
+		// A reader of its own, so concurrent contexts each get all of it.
+		cmd.Stdin = bytes.NewReader(cfg.stdin)
+	}
+	return cmd
+}
+
 // kubectlRunner executes kubectl with the given args. Overridable in tests.
-var kubectlRunner = func(ctx context.Context, args ...string) (stdout, stderr []byte, err error) {
-	cmd := exec.CommandContext(ctx, "kubectl", args...)
-	var outBuf, errBuf strings.Builder
-	cmd.Stdout = &outBuf
+var kubectlRunner = func(ctx context.Context, cfg execConfig, args ...string) (stdout, stderr []byte, err error) {
+	cmd := kubectlCommand(ctx, cfg, args)
+	var errBuf strings.Builder
 	cmd.Stderr = &errBuf
+	if cfg.stdoutLimit > 0 {
+		outBuf := &boundedBuffer{limit: cfg.stdoutLimit}
+		cmd.Stdout = outBuf
+		err = cmd.Run()
+		return outBuf.buf, []byte(errBuf.String()), err
+	}
+	var outBuf strings.Builder
+	cmd.Stdout = &outBuf
 	err = cmd.Run()
 	return []byte(outBuf.String()), []byte(errBuf.String()), err
 }
 
+// kubectlStreamer executes kubectl with its output written to stdout and
+// stderr as it is produced, for long-running commands. Overridable in tests.
+var kubectlStreamer = func(ctx context.Context, cfg execConfig, stdout, stderr io.Writer, args ...string) error {
+	cmd := kubectlCommand(ctx, cfg, args)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// errInterrupted is returned when a run is stopped by SIGINT. The run loops
+// report the interruption themselves, so main only sets the exit status.
+var errInterrupted = errors.New("interrupted")
+
 func main() {
-	if err := newCmd().Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := newCmd().ExecuteContext(ctx)
+	stop()
+	if err != nil && !errors.Is(err, errInterrupted) {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
 	}
+	os.Exit(exitStatus(err))
+}
+
+// options holds the flag values that control a run.
+type options struct {
+	parallel        bool
+	list            bool
+	timeout         time.Duration
+	failFast        bool
+	header          string
+	aliases         []alias
+	format          *template.Template
+	as              string
+	asGroups        []string
+	sortBy          string
+	prefixLines     bool
+	ignoreCase      bool
+	contexts        []string
+	abortAfter      int // stop once this many contexts have failed; 0 = never
+	timeoutAction   string
+	logger          *slog.Logger
+	shuffle         bool
+	seed            int64
+	sample          float64 // percentage of the matched contexts to run against; 0 = all
+	matched         int     // contexts matched before --sample, for --summary-json
+	watch           bool
+	interval        time.Duration
+	noClear         bool
+	watchRefresh    bool
+	explain         bool
+	contextInfo     map[string]contextInfo // loaded once per run for --explain
+	failOnEmpty     bool
+	diff            bool
+	diffBase        string
+	env             []string
+	envMap          []contextRule
+	outputDir       string
+	outputFiles     map[string]string // path prefix per context for --output-dir
+	since           time.Duration
+	kubectlBin      string
+	groupBy         *grouping // nil unless --group-by is set
+	groupCmds       map[string][]string
+	validate        bool
+	expect          string
+	expectRegex     *regexp.Regexp
+	smartHeader     bool
+	currentFirst    bool
+	print0          bool
+	expandArgs      bool
+	kubeFlags       []string
+	tail            bool
+	onFailureArgs   []string
+	exact           bool
+	color           bool
+	countOnly       bool
+	waitFor         time.Duration
+	kubeconfig      string
+	kubeconfigDir   string
+	bufferLimit     int64 // bytes of stdout kept per context; 0 = unlimited
+	output          string
+	records         *[]record       // results collected for --output yaml
+	merged          *[]mergedOutput // results collected for --merge-json
+	skipUnreachable bool
+	beforeEach      string
+	afterEach       string
+	failOnStderr    bool
+	stdin           io.Reader // source of the pattern when it is "-", and of the --confirm-count answer
+	separator       *string   // nil = blank line after headed blocks
+	timeoutMap      []contextRule
+	passTimeout     bool // --propagate-timeout
+	kubeconfigMap   []contextRule
+	tee             string
+	teeStderr       bool
+	timings         bool
+	repeat          int
+	repeatQuiet     bool
+	echo            bool
+	echoOut         io.Writer // where --echo writes, set by execute
+	hookOut         io.Writer // where hook output goes, set by execute
+	matchField      string
+	reverseExitCode bool
+	limit           int
+	retries         int
+	retryOn         *regexp.Regexp
+	stripPrefix     string
+	stripSuffix     string
+	jq              *gojq.Code
+	dedupeByServer  bool
+	errorFormat     string
+	streamOrdered   bool
+	rate            float64 // kubectl starts per second with --parallel; 0 = unlimited
+	forceDangerous  bool
+	confirmCount    bool
+	require         []string
+	stripANSI       bool
+	suppressStdout  bool
+	mergeStreams    bool
+	planOut         string
+	planFlags       []string // flags set on the command line, recorded in the plan
+	order           []string
+	orderStrict     bool
+	cacheTTL        time.Duration
+	mergeJSON       bool
+	interactive     bool
+	sumColumn       int
+	sumRegex        *regexp.Regexp
+	sum             *summer // totals for --sum/--sum-regex in the current run
+	passStdin       bool
+	summaryJSON     string
+	deadline        time.Time
+	showTargets     bool
+	stdinData       []byte // stdin read once for --stdin
+	strictJSON      bool
+	resultCache     *resultCache
+	contextCacheTTL time.Duration
+	noCache         bool
+	finished        *[]result // results collected for --timings and --summary-json
+}
+
+// log returns the run's logger, discarding records when none is configured.
+func (o *options) log() *slog.Logger {
+	if o.logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return o.logger
+}
+
+// baseExec returns the execConfig for reading the kubeconfig: listing the
+// contexts, their details and completions. Its KUBECONFIG also holds the
+// --kubeconfig-map files, so contexts defined only there can be matched; a
+// name defined in several files keeps its first entry, as kubectl merges.
+func (o *options) baseExec() execConfig {
+	cfg := execConfig{bin: o.kubectlBin, env: o.baseEnv(), contextCache: newContextCache(o.contextCacheTTL, o.noCache)}
+	if len(o.kubeconfigMap) > 0 {
+		paths := kubeconfigPaths(cfg)
+		for _, rule := range o.kubeconfigMap {
+			if !slices.Contains(paths, rule.value) {
+				paths = append(paths, rule.value)
+			}
+		}
+		cfg.env = slices.DeleteFunc(cfg.env, func(kv string) bool { return strings.HasPrefix(kv, "KUBECONFIG=") })
+		cfg.env = append(cfg.env, "KUBECONFIG="+strings.Join(paths, string(filepath.ListSeparator)))
+	}
+	return cfg
+}
+
+// baseEnv returns the environment shared by every kubectl call: KUBECONFIG
+// for --kubeconfig and the --kubeconfig-dir files, then the --env pairs.
+// KUBECONFIG is used rather than kubectl's --kubeconfig flag because only it
+// accepts a colon-separated list.
+func (o *options) baseEnv() []string {
+	var paths []string
+	if o.kubeconfig != "" {
+		paths = append(paths, o.kubeconfig)
+	}
+	if o.kubeconfigDir != "" {
+		// prepareOptions has already reported an unreadable directory.
+		files, _ := kubeconfigDirFiles(o.kubeconfigDir)
+		paths = append(paths, files...)
+	}
+	var env []string
+	if len(paths) > 0 {
+		env = append(env, "KUBECONFIG="+strings.Join(paths, string(filepath.ListSeparator)))
+	}
+	return append(env, o.env...)
 }
 
 func newCmd() *cobra.Command {
-	var parallel bool
-	var list bool
-	var timeout time.Duration
-	var failFast bool
-	var header string
+	var opts options
+	var raw rawFlags
 
 	cmd := &cobra.Command{
-		Use:     "kubectl-xctx [flags] <pattern> [-- kubectl args...]",
+		Use:     "kubectl-xctx [flags] (<pattern> | --context <name>...) [-- kubectl args...]",
 		Short:   "Execute kubectl commands across multiple contexts",
 		Version: version,
 		Long: `kubectl-xctx runs a kubectl command across all Kubernetes contexts
@@ -51,57 +284,520 @@ whose name matches a regular expression, printing a labeled header
 for each context's output.
 
 xctx flags must come before the pattern; everything after the pattern
-is passed directly to kubectl.
+is passed directly to kubectl. With --context, no pattern is given and
+all arguments are passed to kubectl.
 
 Examples:
   kubectl xctx "prod" get pods
   kubectl xctx --parallel "staging|dev" get nodes
   kubectl xctx --timeout 10s "." get pods
   kubectl xctx --list "prod"
+  kubectl xctx --expect "Running" "prod" get pods -n ingress
+  kubectl xctx --validate "."
+  kubectl xctx --watch --interval 5s "staging" get pods
+  kubectl xctx --tail "prod" logs deploy/web
+  kubectl xctx --context prod-us-east --context staging-us get pods
+  kubectl xctx -I get pods
+  echo "prod|staging" | kubectl xctx - get pods
   kubectl xctx "prod" get pods -n kube-system
   kubectl xctx --header "=== {context} ===" "prod" get pods
-  kubectl xctx --header "" "prod" get pods -o json | jq .`,
-		Args:          cobra.MinimumNArgs(1),
+  kubectl xctx --header "" "prod" get pods -o json | jq .
+  kubectl xctx --alias "arn:.*:cluster/prod=prod" "prod" get pods
+  kubectl xctx --format "{{.Context}} exit={{.ExitCode}}\n" "." get ns default`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(opts.contexts) > 0 || raw.contextsFile != "" || len(raw.tags) > 0 || len(raw.anyOf) > 0 || opts.interactive {
+				return nil
+			}
+			return asUsage(cobra.MinimumNArgs(1)(cmd, args))
+		},
+		// Cobra checks the mutually exclusive flags after PreRunE without
+		// the flag error func, so check them here to report a usage error.
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			return asUsage(cmd.ValidateFlagGroups())
+		},
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		RunE: func(_ *cobra.Command, args []string) error {
-			return execute(args[0], args[1:], parallel, list, timeout, failFast, header)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := prepareOptions(cmd, &opts, &raw); err != nil {
+				return err
+			}
+			opts.stdin = cmd.InOrStdin()
+			if opts.planOut != "" {
+				opts.planFlags = changedFlags(cmd.Flags())
+			}
+			if len(opts.contexts) > 0 {
+				return execute(cmd.Context(), "", args, &opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			}
+			if len(raw.anyOf) > 0 {
+				return execute(cmd.Context(), anyOfPattern(raw.anyOf), args, &opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			}
+			if opts.interactive {
+				// Offer every context; the selection replaces the pattern.
+				return execute(cmd.Context(), ".", args, &opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			}
+			return execute(cmd.Context(), args[0], args[1:], &opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
 		},
 	}
 
-	cmd.Flags().BoolVarP(&parallel, "parallel", "p", false, "Run across all contexts concurrently")
-	cmd.Flags().BoolVarP(&list, "list", "l", false, "List matching contexts without executing")
-	cmd.Flags().DurationVarP(&timeout, "timeout", "t", 0, "Per-context timeout (e.g. 10s, 1m). 0 = no timeout")
-	cmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop after first failure (sequential mode only)")
-	cmd.Flags().StringVar(&header, "header", "### Context: {context}", `Header printed before each context's output. Use {context} as the placeholder. Set to "" to suppress.`)
+	cmd.Flags().BoolVarP(&opts.parallel, "parallel", "p", false, "Run across all contexts concurrently")
+	cmd.Flags().BoolVarP(&opts.list, "list", "l", false, "List matching contexts without executing")
+	cmd.Flags().BoolVar(&opts.countOnly, "count-only", false, "Print the number of matching contexts without executing")
+	cmd.Flags().BoolVarP(&opts.print0, "print0", "0", false, "With --list, end each context name with a NUL byte instead of a newline (for xargs -0)")
+	cmd.Flags().BoolVar(&opts.skipUnreachable, "skip-unreachable", false, "Check each context's API server first and leave out those that do not answer within 2s")
+	cmd.Flags().BoolVar(&opts.validate, "validate", false, "Check that each matching context's API server is reachable instead of running a command")
+	cmd.Flags().StringArrayVar(&opts.contexts, "context", nil, "Run against this context instead of matching a pattern (repeatable)")
+	cmd.Flags().BoolVarP(&opts.interactive, "interactive", "I", false, "Pick the contexts from a numbered list on the terminal instead of giving a pattern; all arguments are passed to kubectl")
+	cmd.Flags().StringArrayVar(&raw.tags, "tag", nil, "Run against the contexts given this tag in --tags-file instead of matching a pattern; with several, contexts need them all (repeatable)")
+	cmd.Flags().StringVar(&raw.tagsFile, "tags-file", defaultTagsPath(), "YAML file mapping context names to tag lists, for --tag")
+	cmd.Flags().StringArrayVar(&opts.require, "require", nil, "Context that must be among the matched ones; the run fails before starting if any is missing (repeatable)")
+	cmd.Flags().StringVar(&raw.contextsFile, "contexts-file", "", "Run against the contexts listed in this file instead of matching a pattern")
+	cmd.Flags().StringVar(&raw.contextsFileFormat, "contexts-file-format", contextsFileLines, "How --contexts-file lists the contexts: lines (one per line, # comments), csv or yaml (a list of strings)")
+	cmd.Flags().BoolVar(&opts.failOnEmpty, "fail-on-empty", false, "Exit with an error when no contexts match the pattern")
+	cmd.Flags().StringVar(&opts.matchField, "match-field", matchName, `What the pattern is matched against: name, or namespace (each context's default namespace, "default" when unset)`)
+	cmd.Flags().StringArrayVar(&raw.anyOf, "any-of", nil, "Run against contexts containing this literal text instead of matching a pattern, no regex escaping needed; all arguments are passed to kubectl (repeatable)")
+	cmd.Flags().BoolVar(&opts.exact, "exact", false, "Match the pattern against the whole context name rather than any part of it")
+	cmd.Flags().BoolVarP(&opts.ignoreCase, "ignore-case", "i", false, "Match the pattern case-insensitively")
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "For logs commands, only return logs newer than this duration (adds --since to kubectl)")
+	cmd.Flags().StringVar(&raw.orderFile, "order-file", "", "Run the matched contexts in the order this file lists them, one per line; unlisted contexts run last")
+	cmd.Flags().BoolVar(&opts.orderStrict, "order-strict", false, "With --order-file, leave out matched contexts the file does not list")
+	cmd.Flags().BoolVar(&opts.currentFirst, "current-first", false, "Run the kubeconfig's current context first, if it matches")
+	cmd.Flags().BoolVar(&opts.dedupeByServer, "dedupe-by-server", false, "Run only the first of several selected contexts that point at the same API server")
+	cmd.Flags().IntVar(&opts.limit, "limit", 0, "Run against at most this many of the selected contexts, after ordering; with --shuffle, a random sample (0 = all)")
+	cmd.Flags().BoolVar(&opts.shuffle, "shuffle", false, "Run contexts in random order")
+	cmd.Flags().Float64Var(&opts.sample, "sample", 0, "Run against a random percentage (0-100) of the matched contexts, e.g. 10 for a canary check (0 = all)")
+	cmd.Flags().Int64Var(&raw.seed, "seed", 0, "Random seed for --shuffle and --sample, for a reproducible order and selection (default: time-based)")
+	cmd.Flags().DurationVarP(&opts.timeout, "timeout", "t", 0, "Per-context timeout (e.g. 10s, 1m). 0 = no timeout")
+	cmd.Flags().StringVar(&raw.deadline, "deadline", "", `Wall-clock time to stop by, as RFC 3339 or local "HH:MM": contexts still running are cancelled and those not yet started are skipped`)
+	cmd.Flags().BoolVar(&opts.passTimeout, "propagate-timeout", false, "Also pass each context's --timeout to kubectl as --request-timeout, so kubectl gives up in-band instead of being killed (unless the command sets its own)")
+	cmd.Flags().StringArrayVar(&raw.timeoutMap, "timeout-map", nil, "Timeout for contexts matching a regex, as contextRegex=duration; the first match wins over --timeout (repeatable)")
+	cmd.Flags().StringVar(&opts.timeoutAction, "timeout-action", timeoutFail, "What a context hitting --timeout counts as: fail, or skip (reported but not counted as a failure)")
+	cmd.Flags().BoolVar(&opts.failFast, "fail-fast", false, "Stop after the first failure; with --parallel, cancel the contexts still running")
+	cmd.Flags().IntVar(&raw.maxFailures, "max-failures", -1, "Stop once more than this many contexts have failed (0 = stop at the first failure, negative = unlimited)")
+	cmd.Flags().IntVar(&opts.repeat, "repeat", 1, `Run the command this many times in each context and print a "<context>: <passed>/<runs> ok" summary; a context fails if any run does`)
+	cmd.Flags().BoolVar(&opts.repeatQuiet, "repeat-quiet", false, "With --repeat, print only the summary, not each run's output")
+	cmd.Flags().BoolVar(&opts.showTargets, "show-targets", false, "Print the contexts the command will run in, with their count, to stderr before running it")
+	cmd.Flags().StringVar(&opts.planOut, "plan-out", "", "Write the kubectl command for each context to this JSON file for review instead of running it; run it later with \"kubectl xctx apply-plan <file>\"")
+	cmd.Flags().BoolVar(&opts.confirmCount, "confirm-count", false, "Before a command that changes cluster state, require typing the number of contexts it will run in")
+	cmd.Flags().BoolVar(&opts.forceDangerous, "force-dangerous", false, `Allow "delete --all", "apply --prune" and deletes without a resource name across more than one context`)
+	cmd.Flags().IntVar(&opts.retries, "retries", 0, "Re-run a failed context up to this many times, --interval apart")
+	cmd.Flags().StringVar(&raw.retryOn, "retry-on", "", `With --retries, retry only failures whose stderr or error matches this regex (e.g. "TLS handshake timeout|connection refused")`)
+	cmd.Flags().BoolVar(&opts.reverseExitCode, "reverse-exit-code", false, "Invert the exit status: succeed only when every context fails, e.g. to confirm a resource is gone everywhere")
+	cmd.Flags().BoolVar(&opts.failOnStderr, "fail-on-stderr", false, "Fail any context where kubectl writes to stderr, even if it exits 0")
+	cmd.Flags().StringVar(&opts.expect, "expect", "", "Fail any context whose output does not contain this string")
+	cmd.Flags().StringVar(&raw.expectRegex, "expect-regex", "", "Fail any context whose output does not match this regex (^ and $ match at line boundaries)")
+	cmd.Flags().StringVar(&opts.beforeEach, "before-each", "", "Shell command to run before each context, with {context} replaced; if it fails, the context fails without running kubectl")
+	cmd.Flags().StringVar(&opts.afterEach, "after-each", "", "Shell command to run after each context, with {context} replaced")
+	cmd.Flags().StringVar(&raw.onFailureCmd, "on-failure-cmd", "", `kubectl args to run afterwards in each failed context for diagnostics, split on spaces (e.g. "get events --sort-by=.lastTimestamp")`)
+	cmd.Flags().BoolVar(&opts.tail, "tail", false, "Stream a logs command from every context at once, prefixing each line with the context, until interrupted")
+	cmd.Flags().DurationVar(&opts.waitFor, "wait-for", 0, "Re-run the command in each context every --interval until its output meets --expect/--expect-regex, for at most this long")
+	cmd.Flags().BoolVar(&opts.watch, "watch", false, "Re-run the command across all contexts every --interval until interrupted")
+	cmd.Flags().DurationVar(&opts.interval, "interval", 2*time.Second, "Delay between --watch iterations, --wait-for polls and --retries attempts")
+	cmd.Flags().BoolVar(&opts.noClear, "no-clear", false, "Do not clear the screen between --watch iterations")
+	cmd.Flags().BoolVar(&opts.watchRefresh, "watch-refresh", false, "Re-resolve the matching contexts on every --watch iteration")
+	cmd.Flags().BoolVar(&opts.explain, "explain", false, "Look up each context's API server and show it in the header via {server}")
+	cmd.Flags().BoolVar(&opts.diff, "diff", false, "Print each context's output as a unified diff against a baseline context")
+	cmd.Flags().StringVar(&opts.diffBase, "diff-base", "", "Baseline context for --diff (default: the first selected context)")
+	cmd.Flags().StringVar(&opts.header, "header", "### Context: {context}", `Header printed before each context's output. Use {context} as the placeholder ({realcontext} for the unaliased name, {duration} for its run time, {server} with --explain) and ${VAR} for environment variables. Set to "" to suppress.`)
+	cmd.Flags().StringVar(&opts.errorFormat, "error-format", "", `Line printed for a failed context, with {context}, {realcontext}, {error}, {exitcode} and {stderr} placeholders (default: [xctx] context "<name>" failed: <error>)`)
+	cmd.Flags().StringVar(&raw.groupBy, "group-by", "", `Group output under a "<group>:" line per group, keyed by the first capture group of this regex or by the name before this delimiter (e.g. "-")`)
+	cmd.Flags().StringArrayVar(&raw.groupCmds, "group-cmd", nil, `kubectl args to run instead in one --group-by group, as "groupKey=args" split on spaces (e.g. "prod=get pods -n web"); other groups run the usual command (repeatable)`)
+	cmd.Flags().BoolVar(&raw.groupByNamespace, "group-by-namespace", false, `Group output under a "# namespace: <ns>" line per default namespace of the contexts`)
+	cmd.Flags().Float64Var(&opts.rate, "rate", 0, "With --parallel, start at most this many kubectl calls per second, to go easy on shared API servers (0 = unlimited)")
+	cmd.Flags().BoolVar(&opts.streamOrdered, "stream-ordered", false, "With --parallel, print each context's output as soon as it and every context before it have finished, keeping input order")
+	cmd.Flags().StringVar(&opts.sortBy, "sort-output", sortInput, "Order of parallel results: input, duration, status (failures last) or name")
+	cmd.Flags().BoolVar(&opts.mergeStreams, "merge-streams", false, "Write kubectl's stderr and failure lines to stdout, in each context's block, for consumers that read a single stream")
+	cmd.Flags().BoolVar(&opts.suppressStdout, "suppress-stdout-on-error", false, "Drop the stdout of failed contexts, often partial output, showing only their stderr and failure line")
+	cmd.Flags().BoolVar(&opts.stripANSI, "strip-ansi", false, "Remove ANSI escape codes (colors, cursor movement) from kubectl's output, e.g. kubecolor's, before printing it")
+	cmd.Flags().StringVar(&raw.color, "color", colorAuto, "Color each context's header, stable per context: auto (only on a terminal), always or never")
+	cmd.Flags().BoolVar(&opts.timings, "timings", false, "After the run, print how long each context took to stderr, slowest first")
+	cmd.Flags().BoolVar(&opts.smartHeader, "smart-header", false, "Omit the header when only one context matches")
+	cmd.Flags().StringVar(&raw.separator, "separator", "", `Written after each context's output; backslash escapes like \n are expanded, "" disables it (default: a blank line when a header is shown)`)
+	cmd.Flags().BoolVar(&opts.prefixLines, "prefix-lines", false, "Prefix every output line with the context name and a tab instead of printing a header")
+	cmd.Flags().StringVar(&raw.bufferLimit, "buffer-limit", "", `Keep at most this much of each context's stdout (e.g. "64K", "10M"), truncating the rest`)
+	cmd.Flags().StringVar(&opts.outputDir, "output-dir", "", "Write each context's stdout/stderr to <dir>/<context>.out/.err and print only a summary")
+	cmd.Flags().StringVar(&opts.output, "output", "", "Machine-readable output: jsonl (one JSON object per context, printed as each finishes), yaml (one list at the end) or yaml-docs (one YAML document per context)")
+	cmd.Flags().StringVar(&opts.summaryJSON, "summary-json", "", "Also write a JSON summary of the run (counts, and each context's status, exit code and duration) to this file, whatever the output format")
+	cmd.Flags().StringVar(&opts.tee, "tee", "", "Also write the output to this file, created or truncated, while printing it as usual")
+	cmd.Flags().BoolVar(&opts.teeStderr, "tee-stderr", false, "With --tee, write stderr to the file as well")
+	cmd.Flags().StringVar(&raw.format, "format", "", "Go template rendering each context's result, replacing the header layout. Fields: .Context .RealContext .Server .Stdout .Stderr .Err .ExitCode .Index .Total")
+	cmd.Flags().StringVar(&raw.formatFile, "format-file", "", "Read the --format template from this file")
+	cmd.Flags().StringVar(&raw.jq, "jq", "", `jq expression applied to each context's JSON output before printing (e.g. ".items | length"); a context whose output is not JSON or fails the filter fails`)
+	cmd.Flags().IntVar(&opts.sumColumn, "sum", 0, "Add up this 1-based whitespace-separated column of every output line across contexts and print a \"total: <n>\" line at the end")
+	cmd.Flags().StringVar(&raw.sumRegex, "sum-regex", "", "Like --sum, but add up every match of this regex (its first group, if it has one)")
+	cmd.Flags().BoolVar(&opts.mergeJSON, "merge-json", false, `Print one JSON object mapping each context to its output (e.g. with "-o json"); output that is not JSON is included as a string`)
+	cmd.Flags().BoolVar(&opts.strictJSON, "strict-json", false, "With --merge-json, fail any context whose output is not valid JSON instead of including it as a string")
+	cmd.Flags().BoolVar(&opts.passStdin, "stdin", false, `Read stdin once and feed all of it to kubectl in every context, e.g. for "apply -f -"`)
+	cmd.Flags().BoolVar(&opts.echo, "echo", false, "Print each kubectl command to stderr, shell-quoted, just before running it")
+	cmd.Flags().BoolVar(&opts.expandArgs, "expand-args", false, "Replace {context} in the kubectl args with each context's name")
+	cmd.Flags().StringVar(&opts.kubeconfig, "kubeconfig", "", "Kubeconfig file(s) for every kubectl call, colon-separated like $KUBECONFIG")
+	cmd.Flags().StringArrayVar(&raw.kubeconfigMap, "kubeconfig-map", nil, "Kubeconfig file for running in contexts matching a regex, as contextRegex=path, passed to kubectl as --kubeconfig; the first match wins (repeatable)")
+	cmd.Flags().StringVar(&opts.kubeconfigDir, "kubeconfig-dir", "", "Use every *.yaml and *.yml file in this directory as a kubeconfig, merged like $KUBECONFIG (after --kubeconfig, if both are set)")
+	cmd.Flags().DurationVar(&opts.cacheTTL, "cache-ttl", 0, "Reuse a read-only command's output from an identical successful run within this long, instead of calling kubectl again (0 = off)")
+	cmd.Flags().DurationVar(&opts.contextCacheTTL, "context-cache-ttl", time.Minute, "How long the list of kubeconfig contexts is cached between runs and completions; editing a kubeconfig file invalidates it (0 = no cache)")
+	cmd.Flags().BoolVar(&opts.noCache, "no-cache", false, "Ask kubectl for the contexts instead of using the cached list")
+	cmd.Flags().StringVar(&opts.kubectlBin, "kubectl-bin", "kubectl", "kubectl binary to run, as a name on PATH or a path")
+	cmd.Flags().StringArrayVar(&opts.env, "env", nil, "Environment variable for every kubectl invocation, as KEY=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&raw.envMap, "env-map", nil, "Environment variable for contexts matching a regex, as contextRegex=KEY=VALUE (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.kubeFlags, "kube-flag", nil, `Global kubectl flag for every context, placed before the command (e.g. "--request-timeout=5s", repeatable)`)
+	cmd.Flags().StringVar(&opts.as, "as", "", "Username to impersonate in every context (passed to kubectl as --as)")
+	cmd.Flags().StringArrayVar(&opts.asGroups, "as-group", nil, "Group to impersonate in every context (passed to kubectl as --as-group, repeatable)")
+	cmd.Flags().StringVar(&opts.stripPrefix, "strip-prefix", "", `Leave this prefix off context names in output, e.g. "arn:aws:eks:us-east-1:123456789012:cluster/"`)
+	cmd.Flags().StringVar(&opts.stripSuffix, "strip-suffix", "", "Leave this suffix off context names in output")
+	cmd.Flags().StringArrayVar(&raw.aliases, "alias", nil, `Display name for matching contexts, as "nameOrRegex=Display Name" (repeatable)`)
+	cmd.Flags().StringVar(&raw.configPath, "config", defaultConfigPath(), "Config file supplying flag defaults (parallel, timeout, header)")
+	cmd.Flags().StringVar(&raw.logLevel, "log-level", "error", "Diagnostic logging to stderr: error, info (contexts and timing) or debug (kubectl commands)")
+	cmd.Flags().StringVar(&raw.logFormat, "log-format", "text", "Diagnostic log format: text or json")
+	cmd.MarkFlagsMutuallyExclusive("format", "format-file", "prefix-lines", "diff", "output-dir", "output", "merge-json")
+	cmd.MarkFlagsMutuallyExclusive("output", "merge-json", "group-by", "group-by-namespace")
+	cmd.MarkFlagsMutuallyExclusive("fail-fast", "max-failures")
+	// A diff needs every context's output, so it cannot stop early.
+	cmd.MarkFlagsMutuallyExclusive("diff", "fail-fast")
+	cmd.MarkFlagsMutuallyExclusive("diff", "max-failures")
+	cmd.MarkFlagsMutuallyExclusive("reverse-exit-code", "fail-fast")
+	cmd.MarkFlagsMutuallyExclusive("reverse-exit-code", "max-failures")
+	cmd.MarkFlagsMutuallyExclusive("retries", "wait-for")
+	cmd.MarkFlagsMutuallyExclusive("list", "validate", "count-only")
+	cmd.MarkFlagsMutuallyExclusive("tail", "watch", "diff", "format", "format-file", "output-dir")
+	cmd.MarkFlagsMutuallyExclusive("plan-out", "tail", "watch")
+	cmd.MarkFlagsMutuallyExclusive("order-file", "shuffle")
+	cmd.MarkFlagsMutuallyExclusive("shuffle", "sort-output")
+	cmd.MarkFlagsMutuallyExclusive("sum", "sum-regex", "output", "merge-json")
+	cmd.MarkFlagsMutuallyExclusive("stdin", "confirm-count", "interactive", "plan-out")
+	cmd.MarkFlagsMutuallyExclusive("merge-streams", "format", "format-file", "output-dir", "output", "merge-json")
+	// Unknown flags, bad values and conflicting flags are usage errors, for
+	// the subcommands too.
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return asUsage(err)
+	})
 	// Stop flag parsing at the first non-flag argument (the pattern), so that
 	// kubectl flags like -n are not interpreted as xctx flags.
 	cmd.Flags().SetInterspersed(false)
 
-	cmd.ValidArgsFunction = completeArgs
+	// Completion runs in its own process, after xctx's flags are parsed, so
+	// --kubeconfig and --kubectl-bin apply to it through opts.
+	cmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeArgs(cmd, args, toComplete, opts.baseExec())
+	}
+	// Replace cobra's default completion command, which would register
+	// completion under the wrong name for a kubectl plugin.
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.AddCommand(newCompletionCmd())
+	cmd.AddCommand(newVersionCmd())
+	cmd.AddCommand(newApplyPlanCmd())
+	_ = cmd.RegisterFlagCompletionFunc("context", func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeContextNames(toComplete, opts.baseExec())
+	})
+	// Flags taking one of a fixed set of values complete to that set.
+	for flag, values := range map[string][]string{
+		"output":               {outputJSONL, outputYAML, outputYAMLDocs},
+		"sort-output":          {sortInput, sortDuration, sortStatus, sortName},
+		"color":                {colorAuto, colorAlways, colorNever},
+		"timeout-action":       {timeoutFail, timeoutSkip},
+		"match-field":          {matchName, matchNamespace},
+		"contexts-file-format": {contextsFileLines, contextsFileCSV, contextsFileYAML},
+		"log-level":            {"error", "info", "debug"},
+		"log-format":           {"text", "json"},
+	} {
+		_ = cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+	}
 
 	return cmd
 }
 
+// rawFlags holds flag values that prepareOptions parses into options.
+type rawFlags struct {
+	aliases       []string
+	format        string
+	formatFile    string
+	maxFailures   int
+	logLevel      string
+	logFormat     string
+	seed          int64
+	configPath    string
+	envMap        []string
+	groupBy       string
+	expectRegex   string
+	onFailureCmd  string
+	color         string
+	bufferLimit   string
+	separator     string
+	timeoutMap    []string
+	kubeconfigMap []string
+	sumRegex      string
+	tags          []string
+	anyOf         []string
+	deadline      string
+	groupCmds     []string
+	tagsFile      string
+
+	contextsFile       string
+	contextsFileFormat string
+	orderFile          string
+	groupByNamespace   bool
+	retryOn            string
+	jq                 string
+}
+
+// prepareOptions validates flag values and fills in the options derived
+// from them, so that bad input fails before any context runs. Invalid values
+// and combinations are usage errors; failing to read a file named by a flag
+// is not.
+func prepareOptions(cmd *cobra.Command, opts *options, raw *rawFlags) error {
+	cfg, err := loadConfig(raw.configPath)
+	if err != nil {
+		return err
+	}
+	applyConfig(cmd, cfg, opts)
+
+	if opts.timeoutAction != timeoutFail && opts.timeoutAction != timeoutSkip {
+		return asUsage(fmt.Errorf("invalid --timeout-action %q: must be fail or skip", opts.timeoutAction))
+	}
+	if opts.kubeconfigDir != "" {
+		if _, err := kubeconfigDirFiles(opts.kubeconfigDir); err != nil {
+			return err
+		}
+	}
+	if opts.output != "" && !validOutputs[opts.output] {
+		return asUsage(fmt.Errorf("invalid --output %q: must be one of jsonl, yaml, yaml-docs", opts.output))
+	}
+	if !validSortOrders[opts.sortBy] {
+		return asUsage(fmt.Errorf("invalid --sort-output %q: must be one of input, duration, status, name", opts.sortBy))
+	}
+
+	if opts.color, err = useColor(raw.color, cmd.OutOrStdout()); err != nil {
+		return asUsage(err)
+	}
+	if opts.logger, err = newLogger(cmd.ErrOrStderr(), raw.logLevel, raw.logFormat); err != nil {
+		return asUsage(err)
+	}
+	if opts.aliases, err = parseAliases(raw.aliases); err != nil {
+		return asUsage(err)
+	}
+	switch {
+	case raw.format != "":
+		if opts.format, err = parseFormat("format", raw.format); err != nil {
+			return asUsage(err)
+		}
+	case raw.formatFile != "":
+		if opts.format, err = readFormatFile(raw.formatFile); err != nil {
+			return err
+		}
+	}
+
+	opts.seed = raw.seed
+	if !cmd.Flags().Changed("seed") {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	for _, kv := range opts.env {
+		if !validEnvPair(kv) {
+			return asUsage(fmt.Errorf("invalid --env %q: expected KEY=VALUE", kv))
+		}
+	}
+	if opts.envMap, err = parseContextRules("env-map", raw.envMap); err != nil {
+		return asUsage(err)
+	}
+	for _, rule := range opts.envMap {
+		if !validEnvPair(rule.value) {
+			return asUsage(fmt.Errorf("invalid --env-map value %q: expected contextRegex=KEY=VALUE", rule.value))
+		}
+	}
+	if raw.groupBy != "" {
+		if opts.groupBy, err = parseGroupBy(raw.groupBy); err != nil {
+			return asUsage(err)
+		}
+	}
+	if raw.groupByNamespace {
+		opts.groupBy = &grouping{byNamespace: true}
+	}
+	if len(raw.groupCmds) > 0 {
+		if opts.groupBy == nil {
+			return asUsage(fmt.Errorf("--group-cmd requires --group-by or --group-by-namespace"))
+		}
+		if opts.groupCmds, err = parseGroupCmds(raw.groupCmds); err != nil {
+			return asUsage(err)
+		}
+	}
+	if raw.expectRegex != "" {
+		// Multi-line mode, so ^ and $ anchor to lines of kubectl's output.
+		if opts.expectRegex, err = regexp.Compile("(?m)" + raw.expectRegex); err != nil {
+			return asUsage(fmt.Errorf("invalid --expect-regex %q: %w", raw.expectRegex, err))
+		}
+	}
+	opts.onFailureArgs = strings.Fields(raw.onFailureCmd)
+	if cmd.Flags().Changed("separator") {
+		sep, err := strconv.Unquote(`"` + strings.ReplaceAll(raw.separator, `"`, `\"`) + `"`)
+		if err != nil {
+			return asUsage(fmt.Errorf("invalid --separator %q: %w", raw.separator, err))
+		}
+		opts.separator = &sep
+	}
+	if raw.bufferLimit != "" {
+		if opts.bufferLimit, err = parseByteSize(raw.bufferLimit); err != nil {
+			return asUsage(fmt.Errorf("invalid --buffer-limit: %w", err))
+		}
+	}
+	if opts.waitFor > 0 && opts.expect == "" && opts.expectRegex == nil {
+		return asUsage(fmt.Errorf("--wait-for requires --expect or --expect-regex"))
+	}
+	if opts.print0 && !opts.list {
+		return asUsage(fmt.Errorf("--print0 requires --list"))
+	}
+	if raw.jq != "" {
+		if opts.jq, err = compileJQ(raw.jq); err != nil {
+			return asUsage(err)
+		}
+	}
+	if opts.retries < 0 {
+		return asUsage(fmt.Errorf("--retries must not be negative"))
+	}
+	if raw.retryOn != "" {
+		if opts.retries == 0 {
+			return asUsage(fmt.Errorf("--retry-on requires --retries"))
+		}
+		if opts.retryOn, err = regexp.Compile(raw.retryOn); err != nil {
+			return asUsage(fmt.Errorf("invalid --retry-on %q: %w", raw.retryOn, err))
+		}
+	}
+	if opts.rate < 0 {
+		return asUsage(fmt.Errorf("--rate must not be negative"))
+	}
+	if opts.rate > 0 && !opts.parallel {
+		return asUsage(fmt.Errorf("--rate requires --parallel"))
+	}
+	if opts.streamOrdered && !opts.parallel {
+		return asUsage(fmt.Errorf("--stream-ordered requires --parallel"))
+	}
+	if opts.streamOrdered && opts.sortBy != sortInput {
+		return asUsage(fmt.Errorf("--stream-ordered prints in input order and cannot be combined with --sort-output %s", opts.sortBy))
+	}
+	if opts.streamOrdered && opts.groupBy != nil {
+		// Streaming prints each context as it finishes, so a group's
+		// contexts would not stay under one header.
+		return asUsage(fmt.Errorf("--stream-ordered cannot be combined with --group-by or --group-by-namespace"))
+	}
+	if opts.limit < 0 {
+		return asUsage(fmt.Errorf("--limit must not be negative"))
+	}
+	if opts.sample < 0 || opts.sample > 100 {
+		return asUsage(fmt.Errorf("--sample must be between 0 and 100"))
+	}
+	if opts.repeat < 1 {
+		return asUsage(fmt.Errorf("--repeat must be at least 1"))
+	}
+	if opts.repeatQuiet && opts.repeat < 2 {
+		return asUsage(fmt.Errorf("--repeat-quiet requires --repeat"))
+	}
+	if opts.strictJSON && !opts.mergeJSON {
+		return asUsage(fmt.Errorf("--strict-json requires --merge-json"))
+	}
+	if opts.teeStderr && opts.tee == "" {
+		return asUsage(fmt.Errorf("--tee-stderr requires --tee"))
+	}
+	if opts.passTimeout && opts.timeout == 0 && len(raw.timeoutMap) == 0 {
+		return asUsage(fmt.Errorf("--propagate-timeout requires --timeout or --timeout-map"))
+	}
+	if opts.timeoutMap, err = parseContextRules("timeout-map", raw.timeoutMap); err != nil {
+		return asUsage(err)
+	}
+	for _, rule := range opts.timeoutMap {
+		if _, err := time.ParseDuration(rule.value); err != nil {
+			return asUsage(fmt.Errorf("invalid --timeout-map duration %q: %w", rule.value, err))
+		}
+	}
+	if raw.deadline != "" {
+		if opts.deadline, err = parseDeadline(raw.deadline, deadlineNow()); err != nil {
+			return asUsage(err)
+		}
+	}
+	if opts.sumColumn < 0 {
+		return asUsage(fmt.Errorf("--sum must be a column number from 1"))
+	}
+	if raw.sumRegex != "" {
+		if opts.sumRegex, err = regexp.Compile(raw.sumRegex); err != nil {
+			return asUsage(fmt.Errorf("invalid --sum-regex %q: %w", raw.sumRegex, err))
+		}
+	}
+	if opts.kubeconfigMap, err = parseContextRules("kubeconfig-map", raw.kubeconfigMap); err != nil {
+		return asUsage(err)
+	}
+	if opts.diffBase != "" && !opts.diff {
+		return asUsage(fmt.Errorf("--diff-base requires --diff"))
+	}
+	if opts.matchField != matchName && opts.matchField != matchNamespace {
+		return asUsage(fmt.Errorf("invalid --match-field %q: must be name or namespace", opts.matchField))
+	}
+	switch raw.contextsFileFormat {
+	case contextsFileLines, contextsFileCSV, contextsFileYAML:
+	default:
+		return asUsage(fmt.Errorf("invalid --contexts-file-format %q: must be one of lines, csv, yaml", raw.contextsFileFormat))
+	}
+	if raw.contextsFile != "" {
+		names, err := readContextsFile("contexts-file", raw.contextsFile, raw.contextsFileFormat)
+		if err != nil {
+			return err
+		}
+		opts.contexts = append(opts.contexts, names...)
+	}
+	if opts.planOut != "" {
+		if err := checkPlanFlags(cmd.Flags()); err != nil {
+			return asUsage(err)
+		}
+	}
+	if opts.cacheTTL > 0 && (opts.waitFor > 0 || opts.repeat > 1) {
+		// Cached output would answer every poll or pass with the first one.
+		return asUsage(fmt.Errorf("--cache-ttl cannot be combined with --wait-for or --repeat"))
+	}
+	opts.resultCache = newResultCache(opts.cacheTTL)
+	if len(raw.tags) > 0 {
+		names, err := taggedContexts(raw.tagsFile, raw.tags)
+		if err != nil {
+			return err
+		}
+		opts.contexts = append(opts.contexts, names...)
+	}
+	if len(raw.anyOf) > 0 && (len(opts.contexts) > 0 || opts.interactive) {
+		return asUsage(fmt.Errorf("--any-of cannot be combined with --context, --contexts-file, --tag or --interactive"))
+	}
+	if raw.orderFile != "" {
+		if opts.order, err = readContextsFile("order-file", raw.orderFile, contextsFileLines); err != nil {
+			return err
+		}
+	} else if opts.orderStrict {
+		return asUsage(fmt.Errorf("--order-strict requires --order-file"))
+	}
+
+	// Expand ${VAR} once up front; {context} is substituted per context.
+	opts.header = os.ExpandEnv(opts.header)
+	if cmd.Flags().Changed("max-failures") && raw.maxFailures >= 0 {
+		opts.abortAfter = raw.maxFailures + 1
+	}
+	return nil
+}
+
 // completeArgs provides shell completions for positional arguments.
 // With no args yet it suggests context names; once the pattern is provided
-// it delegates to kubectl's own completion for subcommands, resources, etc.
-func completeArgs(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+// (or contexts were named with --context) it delegates to kubectl's own
+// completion for subcommands, resources, etc.
+func completeArgs(cmd *cobra.Command, args []string, toComplete string, cfg execConfig) ([]string, cobra.ShellCompDirective) {
+	if cmd != nil && (cmd.Flags().Changed("context") || cmd.Flags().Changed("contexts-file")) {
+		return completeKubectl(args, toComplete, cfg)
+	}
 	if len(args) == 0 {
-		return completeContextNames(toComplete)
+		return completeContextNames(toComplete, cfg)
 	}
-	return completeKubectl(args[1:], toComplete)
+	return completeKubectl(args[1:], toComplete, cfg)
 }
 
 // completeContextNames returns context names matching the partial input.
-func completeContextNames(toComplete string) ([]string, cobra.ShellCompDirective) {
-	out, _, err := kubectlRunner(context.Background(), "config", "get-contexts", "-o", "name")
+func completeContextNames(toComplete string, cfg execConfig) ([]string, cobra.ShellCompDirective) {
+	names, err := listContexts(cfg)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
 	var completions []string
-	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if name != "" && strings.HasPrefix(name, toComplete) {
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
 			completions = append(completions, name)
 		}
 	}
@@ -110,10 +806,10 @@ func completeContextNames(toComplete string) ([]string, cobra.ShellCompDirective
 
 // completeKubectl delegates completion to kubectl by calling
 // "kubectl __complete <args...> <toComplete>" and parsing its output.
-func completeKubectl(args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+func completeKubectl(args []string, toComplete string, cfg execConfig) ([]string, cobra.ShellCompDirective) {
 	completeArgs := append([]string{"__complete"}, args...)
 	completeArgs = append(completeArgs, toComplete)
-	out, _, err := kubectlRunner(context.Background(), completeArgs...)
+	out, _, err := kubectlRunner(context.Background(), cfg, completeArgs...)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveDefault
 	}
@@ -136,132 +832,1101 @@ func completeKubectl(args []string, toComplete string) ([]string, cobra.ShellCom
 	return lines, directive
 }
 
-type result struct {
-	ctxName string
-	stdout  []byte
-	stderr  []byte
-	err     error
+// contextRule pairs a context-name regex with a value, for the repeatable
+// "contextRegex=value" flags. Like the main pattern, the regex may match
+// anywhere in the name.
+type contextRule struct {
+	re    *regexp.Regexp
+	value string
 }
 
-func execute(pattern string, kubectlArgs []string, parallel, list bool, timeout time.Duration, failFast bool, header string) error {
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+// parseContextRules parses "contextRegex=value" values of the named flag.
+func parseContextRules(flag string, values []string) ([]contextRule, error) {
+	rules := make([]contextRule, 0, len(values))
+	for _, v := range values {
+		pattern, value, ok := strings.Cut(v, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid --%s %q: expected contextRegex=value", flag, v)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s pattern %q: %w", flag, pattern, err)
+		}
+		rules = append(rules, contextRule{re: re, value: value})
+	}
+	return rules, nil
+}
+
+// alias maps context names matching re to a friendlier display name.
+type alias struct {
+	re   *regexp.Regexp
+	name string
+}
+
+// parseAliases parses "nameOrRegex=Display Name" flag values. The left-hand
+// side must match the whole context name, so a plain name matches only itself.
+func parseAliases(values []string) ([]alias, error) {
+	aliases := make([]alias, 0, len(values))
+	for _, v := range values {
+		pattern, name, ok := strings.Cut(v, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid alias %q: expected nameOrRegex=Display Name", v)
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias pattern %q: %w", pattern, err)
+		}
+		aliases = append(aliases, alias{re: re, name: name})
+	}
+	return aliases, nil
+}
+
+// displayName returns the first alias matching ctxName, or else ctxName
+// without its --strip-prefix and --strip-suffix. It is for output only;
+// kubectl always gets the full name.
+func displayName(ctxName string, opts *options) string {
+	for _, a := range opts.aliases {
+		if a.re.MatchString(ctxName) {
+			return a.name
+		}
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(ctxName, opts.stripPrefix), opts.stripSuffix)
+	if name == "" {
+		return ctxName
 	}
+	return name
+}
 
-	contexts, err := matchingContexts(re)
+type result struct {
+	ctxName   string
+	stdout    []byte
+	stderr    []byte
+	err       error
+	index     int // position of the context within the run
+	total     int // number of contexts in the run
+	duration  time.Duration
+	skipped   bool // timed out with --timeout-action skip
+	truncated bool // stdout was cut at --buffer-limit
+}
+
+// failed reports whether r counts toward the run's failures.
+func (r result) failed() bool {
+	return r.err != nil && !r.skipped
+}
+
+// Values accepted by --timeout-action.
+const (
+	timeoutFail = "fail"
+	timeoutSkip = "skip"
+)
+
+func execute(ctx context.Context, pattern string, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	if pattern == "-" && opts.passStdin {
+		return asUsage(fmt.Errorf("--stdin cannot be combined with reading the pattern from stdin"))
+	}
+	if pattern == "-" && opts.confirmCount {
+		// The answer would be read from the stdin the pattern came from.
+		return asUsage(fmt.Errorf("--confirm-count cannot be combined with reading the pattern from stdin"))
+	}
+	if pattern == "-" && len(opts.contexts) == 0 {
+		var err error
+		if pattern, err = readPattern(opts.stdin); err != nil {
+			return err
+		}
+	}
+	contexts, err := resolveContexts(pattern, opts, errOut)
 	if err != nil {
 		return err
 	}
+	if opts.interactive && len(contexts) > 0 {
+		if contexts, err = contextSelector(contexts, opts.stdin, errOut); err != nil {
+			return err
+		}
+	}
+
+	if opts.countOnly {
+		_, _ = fmt.Fprintln(out, len(contexts))
+		return nil
+	}
 
 	if len(contexts) == 0 {
-		fmt.Fprintf(os.Stderr, "no contexts matched pattern %q\n", pattern)
+		if kubectlVerbs[pattern] {
+			_, _ = fmt.Fprintln(errOut, "[xctx] hint: did you forget the context pattern? Usage: kubectl xctx <pattern> -- <kubectl args>")
+		}
+		if opts.failOnEmpty {
+			return fmt.Errorf("%w pattern %q", ErrNoMatch, pattern)
+		}
+		_, _ = fmt.Fprintf(errOut, "no contexts matched pattern %q\n", pattern)
 		return nil
 	}
 
-	if list {
+	if opts.list {
+		// Like find -print0, end each name with NUL for xargs -0.
+		term := "\n"
+		if opts.print0 {
+			term = "\x00"
+		}
 		for _, c := range contexts {
-			fmt.Println(c)
+			_, _ = io.WriteString(out, c+term)
 		}
 		return nil
 	}
+	if opts.validate {
+		return runValidate(ctx, contexts, opts, out, errOut)
+	}
 
 	if len(kubectlArgs) == 0 {
-		return fmt.Errorf("no kubectl command provided (use -- to separate kubectl args, e.g. kubectl xctx \"prod\" -- get pods)")
+		return asUsage(fmt.Errorf("no kubectl command provided (use -- to separate kubectl args, e.g. kubectl xctx \"prod\" -- get pods)"))
+	}
+	if len(contexts) > 1 && !opts.forceDangerous {
+		for _, args := range allCommands(kubectlArgs, opts) {
+			if combo := dangerousCombo(args); combo != "" {
+				return fmt.Errorf("refusing to run %s across %d contexts; pass --force-dangerous if this is intended", combo, len(contexts))
+			}
+		}
+	}
+
+	kubectlArgs = withSince(kubectlArgs, opts, errOut)
+	if opts.skipUnreachable {
+		total := len(contexts)
+		contexts = dropUnreachable(ctx, contexts, opts, errOut)
+		if interruptedRun(ctx) {
+			return interrupted(errOut)
+		}
+		if len(contexts) == 0 {
+			return fmt.Errorf("all %d context(s) unreachable", total)
+		}
+	}
+	if opts.showTargets {
+		_, _ = fmt.Fprintf(errOut, "[xctx] %d target context(s):\n", len(contexts))
+		for _, c := range contexts {
+			_, _ = fmt.Fprintf(errOut, "  %s\n", displayName(c, opts))
+		}
+	}
+	if opts.planOut != "" {
+		return writePlan(pattern, contexts, kubectlArgs, opts, errOut)
+	}
+	if opts.confirmCount {
+		for _, args := range allCommands(kubectlArgs, opts) {
+			if !mutating(args) {
+				continue
+			}
+			if err := confirmCount(opts.stdin, errOut, args, len(contexts)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if opts.passStdin {
+		if opts.stdinData, err = io.ReadAll(opts.stdin); err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+	if opts.smartHeader && len(contexts) == 1 {
+		opts.header = ""
+	}
+
+	if opts.explain {
+		if err := ensureContextInfo(opts); err != nil {
+			return err
+		}
+	}
+	if opts.outputDir != "" {
+		if err := os.MkdirAll(opts.outputDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		opts.outputFiles = resultFileBases(opts.outputDir, contexts)
+	}
+	if opts.tee != "" {
+		f, err := os.Create(opts.tee) //nolint:gosec // path is the user's own flag value
+		if err != nil {
+			return fmt.Errorf("failed to create --tee file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = io.MultiWriter(out, f)
+		if opts.teeStderr {
+			errOut = io.MultiWriter(errOut, f)
+		}
+	}
+	if opts.echo || opts.beforeEach != "" || opts.afterEach != "" {
+		// Echoed commands and hook output come from every goroutine of a
+		// parallel run.
+		errOut = &syncWriter{w: errOut}
 	}
+	if opts.echo {
+		opts.echoOut = errOut
+	}
+	opts.hookOut = errOut
 
-	if parallel {
-		return runParallel(contexts, kubectlArgs, timeout, header, os.Stdout, os.Stderr)
+	// --deadline bounds everything from here: the run, each --watch
+	// iteration and the --tail streams.
+	ctx, cancel := withDeadline(ctx, opts)
+	defer cancel()
+	if opts.tail {
+		return runTail(ctx, contexts, kubectlArgs, opts, out, errOut)
+	}
+	if opts.watch {
+		return watch(ctx, pattern, contexts, kubectlArgs, opts, out, errOut)
+	}
+	err = run(ctx, contexts, kubectlArgs, opts, out, errOut)
+	if opts.reverseExitCode {
+		return reverseExitCode(err, contexts, errOut)
+	}
+	return err
+}
+
+// reverseExitCode inverts a run's outcome for --reverse-exit-code: it
+// succeeds only when every context failed, and otherwise names the contexts
+// that succeeded.
+func reverseExitCode(err error, contexts []string, errOut io.Writer) error {
+	if errors.Is(err, errInterrupted) {
+		return err
 	}
-	return runSequential(contexts, kubectlArgs, timeout, failFast, header, os.Stdout, os.Stderr)
+	failed := map[string]bool{}
+	var m *MultiError
+	if errors.As(err, &m) {
+		for _, e := range m.Errors {
+			failed[e.Context] = true
+		}
+	}
+	var succeeded []string
+	for _, name := range contexts {
+		if !failed[name] {
+			succeeded = append(succeeded, name)
+		}
+	}
+	if len(succeeded) > 0 {
+		return fmt.Errorf("%d of %d context(s) succeeded, expected all to fail (--reverse-exit-code): %s", len(succeeded), len(contexts), strings.Join(succeeded, ", "))
+	}
+	_, _ = fmt.Fprintf(errOut, "[xctx] all %d context(s) failed, as expected (--reverse-exit-code)\n", len(contexts))
+	return nil
 }
 
-func matchingContexts(re *regexp.Regexp) ([]string, error) {
-	out, _, err := kubectlRunner(context.Background(), "config", "get-contexts", "-o", "name")
+// run executes kubectlArgs once across contexts in the configured mode.
+func run(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	if opts.output == outputYAML {
+		// Collect every record, including --on-failure-cmd's, into one list.
+		opts.records = new([]record)
+		defer func() { writeYAMLList(*opts.records, out, errOut) }()
+	}
+	if opts.mergeJSON {
+		opts.merged = new([]mergedOutput)
+		defer func() { writeMergedJSON(*opts.merged, out, errOut) }()
+	}
+	if opts.timings || opts.summaryJSON != "" {
+		opts.finished = new([]result)
+	}
+	if (opts.sumColumn > 0 || opts.sumRegex != nil) && !opts.diff {
+		opts.sum = &summer{column: opts.sumColumn, re: opts.sumRegex}
+	}
+	start := time.Now()
+	var err error
+	switch {
+	case opts.diff:
+		err = runDiff(ctx, contexts, kubectlArgs, opts, out, errOut)
+	case opts.repeat > 1:
+		err = runRepeated(ctx, contexts, kubectlArgs, opts, out, errOut)
+	case opts.parallel:
+		err = runParallel(ctx, contexts, kubectlArgs, opts, out, errOut)
+	default:
+		err = runSequential(ctx, contexts, kubectlArgs, opts, out, errOut)
+	}
+	if opts.timings && !interruptedRun(ctx) {
+		printTimings(*opts.finished, opts, errOut)
+	}
+	if opts.sum != nil && !interruptedRun(ctx) {
+		opts.sum.print(out)
+	}
+	if opts.summaryJSON != "" {
+		// Written even when interrupted: a partial report beats none.
+		if werr := writeSummary(opts.summaryJSON, contexts, opts.matched, *opts.finished, time.Since(start)); werr != nil {
+			_, _ = fmt.Fprintf(errOut, "[xctx] %v\n", werr)
+			if err == nil {
+				err = werr
+			}
+		}
+	}
+	runOnFailure(ctx, err, opts, out, errOut)
+	return err
+}
+
+// readPattern reads the context pattern from the first line of in, for a
+// pattern given as "-".
+func readPattern(in io.Reader) (string, error) {
+	if in == nil {
+		in = os.Stdin
+	}
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read pattern from stdin: %w", err)
+	}
+	pattern := strings.TrimSpace(line)
+	if pattern == "" {
+		return "", fmt.Errorf("pattern \"-\" given but stdin has no pattern")
+	}
+	return pattern, nil
+}
+
+// resolveContexts selects the contexts for a run and puts them in run order.
+func resolveContexts(pattern string, opts *options, errOut io.Writer) ([]string, error) {
+	contexts, err := selectContexts(pattern, opts, errOut)
+	if err != nil {
+		return nil, err
+	}
+	if missing := missingContexts(opts.require, contexts); len(missing) > 0 {
+		return nil, fmt.Errorf("required context(s) not matched: %s", strings.Join(missing, ", "))
+	}
+	if opts.order != nil {
+		contexts = orderContexts(contexts, opts.order, opts.orderStrict)
+	}
+	if opts.shuffle {
+		shuffleContexts(contexts, opts.seed)
+	}
+	if opts.currentFirst {
+		moveCurrentFirst(contexts, opts)
+	}
+	if opts.groupBy != nil {
+		if opts.groupBy.byNamespace {
+			if err := loadNamespaces(opts.groupBy, opts); err != nil {
+				return nil, err
+			}
+		}
+		groupContexts(contexts, opts.groupBy)
+	}
+	if opts.dedupeByServer {
+		if contexts, err = dedupeByServer(contexts, opts, errOut); err != nil {
+			return nil, err
+		}
+	}
+	if opts.sample > 0 {
+		opts.matched = len(contexts)
+		contexts = sampleContexts(contexts, opts.sample, opts.seed)
+		_, _ = fmt.Fprintf(errOut, "[xctx] sampled %d of %d matched context(s)\n", len(contexts), opts.matched)
+	}
+	if opts.limit > 0 && len(contexts) > opts.limit {
+		_, _ = fmt.Fprintf(errOut, "[xctx] limited to %d of %d matched context(s)\n", opts.limit, len(contexts))
+		contexts = contexts[:opts.limit]
+	}
+	opts.log().Info("selected contexts", "count", len(contexts), "contexts", contexts)
+	return contexts, nil
+}
+
+// missingContexts returns the --require names absent from contexts.
+func missingContexts(required, contexts []string) []string {
+	var missing []string
+	for _, name := range required {
+		if !slices.Contains(contexts, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// selectContexts returns the contexts to run against: the explicit --context
+// names if given, otherwise the kubeconfig contexts matching pattern.
+func selectContexts(pattern string, opts *options, errOut io.Writer) ([]string, error) {
+	if len(opts.contexts) > 0 {
+		return explicitContexts(opts.contexts, opts.baseExec(), errOut)
+	}
+	re, err := compilePattern(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.matchField == matchNamespace {
+		return matchingNamespaces(re, opts.baseExec())
+	}
+	return matchingContexts(re, opts.baseExec())
+}
+
+// explicitContexts returns names as given, warning about any that are not
+// in the kubeconfig. Unknown names are kept so kubectl reports the error.
+func explicitContexts(names []string, cfg execConfig, errOut io.Writer) ([]string, error) {
+	all, err := listContexts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(all))
+	for _, name := range all {
+		known[name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			_, _ = fmt.Fprintf(errOut, "[xctx] warning: context %q not found in kubeconfig\n", name)
+		}
+	}
+	return names, nil
+}
+
+// moveCurrentFirst moves the kubeconfig's current context to the front of
+// contexts, keeping the others in order. Without a current context, or when
+// it is not selected, contexts is left as is.
+func moveCurrentFirst(contexts []string, opts *options) {
+	current, err := currentContext(opts.baseExec())
+	if err != nil {
+		opts.log().Info("no current context", "error", err)
+		return
+	}
+	for i, name := range contexts {
+		if name == current {
+			copy(contexts[1:i+1], contexts[:i])
+			contexts[0] = current
+			return
+		}
+	}
+}
+
+// currentContext returns the kubeconfig's current-context.
+func currentContext(cfg execConfig) (string, error) {
+	out, _, err := kubectlRunner(context.Background(), cfg, "config", "current-context")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current context: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// shuffleContexts randomizes the order of contexts in place. The same seed
+// always produces the same order.
+func shuffleContexts(contexts []string, seed int64) {
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec // ordering only, not security sensitive
+	rng.Shuffle(len(contexts), func(i, j int) { contexts[i], contexts[j] = contexts[j], contexts[i] })
+}
+
+// sampleContexts returns a random percent of contexts, rounded up so a
+// non-empty selection keeps at least one, in their original order. The same
+// seed always picks the same contexts.
+func sampleContexts(contexts []string, percent float64, seed int64) []string {
+	n := int(math.Ceil(float64(len(contexts)) * percent / 100))
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec // sampling only, not security sensitive
+	picked := rng.Perm(len(contexts))[:n]
+	sort.Ints(picked)
+	sampled := make([]string, n)
+	for i, p := range picked {
+		sampled[i] = contexts[p]
+	}
+	return sampled
+}
+
+// Values accepted by --match-field.
+const (
+	matchName      = "name"
+	matchNamespace = "namespace"
+)
+
+// newLogger returns a slog logger writing to w. Logs always go to stderr so
+// they never mix with kubectl output on stdout.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	switch level {
+	case "error":
+		lvl = slog.LevelError
+	case "info":
+		lvl = slog.LevelInfo
+	case "debug":
+		lvl = slog.LevelDebug
+	default:
+		return nil, fmt.Errorf("invalid --log-level %q: must be error, info or debug", level)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q: must be text or json", format)
+	}
+}
+
+// anyOfPattern returns the pattern matching any of the --any-of values,
+// taken literally.
+func anyOfPattern(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// compilePattern compiles the context pattern, applying the matching flags.
+func compilePattern(pattern string, opts *options) (*regexp.Regexp, error) {
+	expr := pattern
+	if opts.exact {
+		expr = "^(?:" + expr + ")$"
+	}
+	if opts.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+	}
+	return re, nil
+}
+
+func matchingContexts(re *regexp.Regexp, cfg execConfig) ([]string, error) {
+	all, err := listContexts(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list kubectl contexts: %w", err)
+		return nil, err
 	}
 
 	var matched []string
-	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if name != "" && re.MatchString(name) {
+	for _, name := range all {
+		if re.MatchString(name) {
 			matched = append(matched, name)
 		}
 	}
 	return matched, nil
 }
 
-func runInContext(ctx context.Context, ctxName string, args []string) result {
-	stdout, stderr, err := kubectlRunner(ctx, append([]string{"--context", ctxName}, args...)...)
-	return result{ctxName: ctxName, stdout: stdout, stderr: stderr, err: err}
+// listContexts returns every context name in the kubeconfig.
+// A missing kubectl binary gets a friendlier message than exec's own.
+func listContexts(cfg execConfig) ([]string, error) {
+	if cfg.contextCache != nil {
+		if names, ok := cfg.contextCache.get(cfg); ok {
+			return names, nil
+		}
+	}
+	names, err := xctx.ListContexts(context.Background(), cfg.runner())
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s not found on PATH; install it or set --kubectl-bin", cfg.binary())
+	}
+	if err == nil && cfg.contextCache != nil {
+		cfg.contextCache.put(cfg, names)
+	}
+	return names, err
+}
+
+// contextArgs returns the full kubectl args for running args in ctxName.
+func contextArgs(ctxName string, args []string, opts *options) []string {
+	fullArgs := []string{"--context", ctxName}
+	if path := kubeconfigFor(ctxName, opts); path != "" {
+		fullArgs = append(fullArgs, "--kubeconfig="+path)
+	}
+	fullArgs = append(fullArgs, globalFlags(opts)...)
+	if d := timeoutFor(ctxName, opts); opts.passTimeout && d > 0 && !hasRequestTimeout(fullArgs) && !hasRequestTimeout(args) {
+		fullArgs = append(fullArgs, "--request-timeout="+d.String())
+	}
+	if opts.expandArgs {
+		args = expandContextArgs(args, ctxName)
+	}
+	return append(fullArgs, args...)
+}
+
+// hasRequestTimeout reports whether args set kubectl's --request-timeout
+// before any "--".
+func hasRequestTimeout(args []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			return false
+		}
+		if a == "--request-timeout" || strings.HasPrefix(a, "--request-timeout=") {
+			return true
+		}
+	}
+	return false
+}
+
+// runRecovered is runInContext for the run loops: a panic while handling
+// ctxName fails that context, with the panic as its error, instead of
+// crashing the program and losing the other contexts' results.
+func runRecovered(ctx context.Context, ctxName string, args []string, opts *options) (r result) {
+	defer func() {
+		if p := recover(); p != nil {
+			opts.log().Debug("recovered panic", "context", ctxName, "stack", string(debug.Stack()))
+			r = result{ctxName: ctxName, err: fmt.Errorf("panic: %v", p)}
+		}
+	}()
+	return runInContext(ctx, ctxName, args, opts)
 }
 
-func printResult(r result, header string, out, errOut io.Writer) {
-	if header != "" {
-		_, _ = fmt.Fprintln(out, strings.ReplaceAll(header, "{context}", r.ctxName))
+// runInContext runs args in ctxName, polling until it succeeds when
+// --wait-for is set, between the --before-each and --after-each hooks. A
+// failed before-each hook fails the context without running kubectl.
+func runInContext(ctx context.Context, ctxName string, args []string, opts *options) result {
+	args = argsFor(ctxName, args, opts)
+	if opts.beforeEach != "" {
+		if err := runHook(ctx, "before-each", opts.beforeEach, ctxName, opts); err != nil {
+			return result{ctxName: ctxName, err: err}
+		}
+	}
+	// --timeout bounds each kubectl attempt in runAttempt, so the hooks run
+	// outside it and an after-each hook still runs when kubectl timed out.
+	var r result
+	switch {
+	case opts.waitFor > 0:
+		r = waitInContext(ctx, ctxName, args, opts)
+	case opts.retries > 0:
+		r = retryInContext(ctx, ctxName, args, opts)
+	default:
+		r = runAttempt(ctx, ctxName, args, opts)
+	}
+	if opts.afterEach != "" {
+		if err := runHook(ctx, "after-each", opts.afterEach, ctxName, opts); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r
+}
+
+// runAttempt runs args once in ctxName, bounded by its --timeout.
+func runAttempt(ctx context.Context, ctxName string, args []string, opts *options) result {
+	ctx, cancel := maybeWithTimeout(ctx, timeoutFor(ctxName, opts))
+	defer cancel()
+	fullArgs := contextArgs(ctxName, args, opts)
+	opts.log().Debug("running kubectl", "context", ctxName, "command", "kubectl "+strings.Join(fullArgs, " "))
+	start := time.Now()
+	cfg := execConfig{bin: opts.kubectlBin, env: envFor(ctxName, opts), stdoutLimit: opts.bufferLimit, stdin: opts.stdinData}
+	if opts.echoOut != nil {
+		echoCommand(opts.echoOut, cfg, fullArgs)
+	}
+	stdout, stderr, err := cachedKubectl(ctx, cfg, args, fullArgs, opts)
+	r := result{ctxName: ctxName, stderr: stderr, err: err, duration: time.Since(start)}
+	r.stdout, r.truncated = truncateOutput(stdout, opts.bufferLimit)
+	if r.err == nil && opts.jq != nil {
+		// On a filter error the raw output is kept, to show what failed.
+		if filtered, err := applyJQ(opts.jq, r.stdout); err != nil {
+			r.err = err
+		} else {
+			r.stdout = filtered
+		}
+	}
+	opts.log().Info("context finished", "context", ctxName, "duration", r.duration, "error", err)
+	if cause := context.Cause(ctx); err != nil && (errors.Is(cause, errDeadlineReached) || errors.Is(cause, errWaitExpired)) {
+		r.err = fmt.Errorf("%w (%v)", cause, err)
+	} else if err := xctx.DeadlineError(ctx, err); errors.Is(err, context.DeadlineExceeded) {
+		r.err = err
+		r.skipped = opts.timeoutAction == timeoutSkip
+	}
+	if r.err == nil {
+		r.err = checkExpect(r.stdout, opts)
+	}
+	if r.err == nil {
+		r.err = checkStrictJSON(r.stdout, opts)
+	}
+	if r.err == nil && opts.failOnStderr && len(r.stderr) > 0 {
+		r.err = errors.New("kubectl wrote to stderr (--fail-on-stderr)")
+	}
+	return r
+}
+
+// cachedKubectl runs kubectl with fullArgs, answering from the --cache-ttl
+// cache when it holds the output. Only read-only commands, judged by args,
+// are cached, and only when they succeed.
+func cachedKubectl(ctx context.Context, cfg execConfig, args, fullArgs []string, opts *options) ([]byte, []byte, error) {
+	if opts.resultCache == nil || !cacheable(args) {
+		return kubectlRunner(ctx, cfg, fullArgs...)
+	}
+	if stdout, ok := opts.resultCache.get(cfg, fullArgs); ok {
+		opts.log().Debug("using cached output", "command", "kubectl "+strings.Join(fullArgs, " "))
+		return stdout, nil, nil
+	}
+	stdout, stderr, err := kubectlRunner(ctx, cfg, fullArgs...)
+	if err == nil {
+		opts.resultCache.put(cfg, fullArgs, stdout)
+	}
+	return stdout, stderr, err
+}
+
+// expandContextArgs returns a copy of args with every {context} replaced by
+// ctxName, for --expand-args.
+func expandContextArgs(args []string, ctxName string) []string {
+	expanded := make([]string, len(args))
+	for i, a := range args {
+		expanded[i] = strings.ReplaceAll(a, "{context}", ctxName)
+	}
+	return expanded
+}
+
+// envFor returns the extra environment for ctxName: the shared baseEnv
+// followed by every matching --env-map pair, so mapped values take precedence.
+func envFor(ctxName string, opts *options) []string {
+	env := opts.baseEnv()
+	for _, rule := range opts.envMap {
+		if rule.re.MatchString(ctxName) {
+			env = append(env, rule.value)
+		}
+	}
+	return env
+}
+
+// validEnvPair reports whether kv has the form KEY=VALUE.
+func validEnvPair(kv string) bool {
+	key, _, ok := strings.Cut(kv, "=")
+	return ok && key != ""
+}
+
+// kubectlVerbs are common kubectl subcommands. A pattern equal to one of
+// them that matches no context was most likely meant as the command.
+var kubectlVerbs = map[string]bool{
+	"get": true, "describe": true, "delete": true, "apply": true, "create": true,
+	"edit": true, "patch": true, "logs": true, "exec": true, "rollout": true,
+	"scale": true, "top": true, "label": true, "annotate": true, "diff": true,
+	"explain": true, "port-forward": true, "auth": true, "version": true,
+}
+
+// withSince appends --since to a logs command. For any other command the
+// flag does not apply, so it is dropped with a warning.
+func withSince(args []string, opts *options, errOut io.Writer) []string {
+	if opts.since <= 0 {
+		return args
+	}
+	if verb, _, _ := parseKubectlArgs(args); verb != "logs" {
+		_, _ = fmt.Fprintf(errOut, "[xctx] warning: --since only applies to logs, ignoring it for %q\n", verb)
+		return args
+	}
+	return append(args[:len(args):len(args)], "--since="+opts.since.String())
+}
+
+// globalFlags returns the kubectl global flags xctx applies to every context,
+// placed before the user's own args.
+func globalFlags(opts *options) []string {
+	var flags []string
+	if opts.as != "" {
+		flags = append(flags, "--as="+opts.as)
+	}
+	for _, g := range opts.asGroups {
+		flags = append(flags, "--as-group="+g)
+	}
+	return append(flags, opts.kubeFlags...)
+}
+
+// renderHeader substitutes the context placeholders in the header template.
+func renderHeader(r result, opts *options) string {
+	header := opts.header
+	if opts.explain && !strings.Contains(header, "{server}") {
+		header += " ({server})"
+	}
+	return strings.NewReplacer(
+		"{context}", displayName(r.ctxName, opts),
+		"{realcontext}", r.ctxName,
+		"{server}", opts.contextInfo[r.ctxName].server,
+		"{duration}", formatDuration(r.duration),
+	).Replace(header)
+}
+
+func printResult(r result, opts *options, out, errOut io.Writer) {
+	if opts.stripANSI {
+		r.stdout, r.stderr = stripANSI(r.stdout), stripANSI(r.stderr)
+	}
+	if opts.suppressStdout && r.err != nil {
+		r.stdout = nil
+	}
+	if opts.sum != nil {
+		opts.sum.add(r, opts, errOut)
+	}
+	if opts.format != nil {
+		printFormatted(r, opts, out, errOut)
+		return
+	}
+	if opts.outputDir != "" {
+		writeResultFiles(r, opts, out, errOut)
+		return
+	}
+	if opts.merged != nil {
+		addMerged(r, opts, errOut)
+		return
+	}
+	switch opts.output {
+	case outputJSONL:
+		writeJSONL(r, out, errOut)
+		return
+	case outputYAMLDocs:
+		writeYAMLDoc(r, out, errOut)
+		return
+	case outputYAML:
+		*opts.records = append(*opts.records, newRecord(r))
+		return
+	}
+	// --merge-streams keeps kubectl's stderr and xctx's failure line in the
+	// context's block on stdout.
+	if opts.mergeStreams {
+		errOut = out
+	}
+	// --prefix-lines tags every line with the context instead of a block header.
+	header := opts.header
+	if opts.prefixLines {
+		header = ""
+		writePrefixed(out, displayName(r.ctxName, opts), r.stdout)
+		if opts.mergeStreams {
+			writePrefixed(out, displayName(r.ctxName, opts), r.stderr)
+			r.stderr = nil
+		}
+	} else {
+		if header != "" {
+			h := renderHeader(r, opts)
+			if opts.color {
+				h = colorize(h, r.ctxName)
+			}
+			_, _ = fmt.Fprintln(out, h)
+		}
+		_, _ = out.Write(r.stdout)
 	}
-	_, _ = out.Write(r.stdout)
 	if len(r.stderr) > 0 {
 		_, _ = errOut.Write(r.stderr)
 	}
-	if r.err != nil {
+	printFailure(r, opts, errOut)
+	writeSeparator(out, header, opts)
+}
+
+// writeSeparator ends a context's block: with --separator when given,
+// otherwise with a blank line when the block had a header.
+func writeSeparator(out io.Writer, header string, opts *options) {
+	if opts.separator != nil {
+		_, _ = io.WriteString(out, *opts.separator)
+	} else if header != "" {
+		_, _ = fmt.Fprintln(out)
+	}
+}
+
+// printFailure reports a failed or skipped context on errOut, along with
+// output cut short by --buffer-limit. A failure is written with
+// --error-format when one is set; otherwise a timeout gets its own line.
+func printFailure(r result, opts *options, errOut io.Writer) {
+	if r.truncated {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q: output truncated (--buffer-limit)\n", r.ctxName)
+	}
+	switch {
+	case r.skipped:
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q skipped: %v\n", r.ctxName, r.err)
+	case r.err != nil && opts.errorFormat != "":
+		_, _ = fmt.Fprintln(errOut, strings.NewReplacer(
+			"{context}", displayName(r.ctxName, opts),
+			"{realcontext}", r.ctxName,
+			"{error}", r.err.Error(),
+			"{exitcode}", strconv.Itoa(exitCode(r.err)),
+			"{stderr}", strings.TrimSpace(string(r.stderr)),
+		).Replace(opts.errorFormat))
+	case errors.Is(r.err, context.DeadlineExceeded):
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q timed out after %s\n", r.ctxName, r.duration.Round(time.Millisecond))
+	case r.err != nil:
 		_, _ = fmt.Fprintf(errOut, "[xctx] context %q failed: %v\n", r.ctxName, r.err)
 	}
-	if header != "" {
-		_, _ = fmt.Fprintln(out)
+}
+
+// writePrefixed writes each line of data to out as "<prefix>\t<line>".
+func writePrefixed(out io.Writer, prefix string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		_, _ = fmt.Fprintf(out, "%s\t%s\n", prefix, line)
 	}
 }
 
-func runSequential(contexts, kubectlArgs []string, timeout time.Duration, failFast bool, header string, out, errOut io.Writer) error {
-	var failed int
-	for _, ctxName := range contexts {
-		ctx, cancel := maybeWithTimeout(timeout)
-		r := runInContext(ctx, ctxName, kubectlArgs)
-		cancel()
-		printResult(r, header, out, errOut)
-		if r.err != nil {
-			failed++
-			if failFast {
-				return fmt.Errorf("stopped after failure in context %q (%d context(s) failed)", ctxName, failed)
-			}
+// runSequential runs kubectlArgs in each context in turn. A context's output
+// is captured in full before any of it is printed, so its stdout, stderr and
+// failure note stay together rather than interleaving with the next
+// context's.
+func runSequential(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	var failed []result
+	var groups groupHeaders
+	results, stopped := runContexts(ctx, contexts, kubectlArgs, opts, false, func(r result) {
+		groups.print(r, opts, out)
+		printResult(r, opts, out, errOut)
+		if r.failed() {
+			failed = append(failed, r)
 		}
+	})
+	if interruptedRun(ctx) {
+		return interrupted(errOut)
+	}
+	if stopped {
+		return fmt.Errorf("stopped after failure in context %q (%w)", failed[len(failed)-1].ctxName, newMultiError(failed))
 	}
-	if failed > 0 {
-		return fmt.Errorf("%d context(s) failed", failed)
+	if unrun := notRun(contexts, results); len(unrun) > 0 {
+		return notStarted(unrun, failed, opts, errOut)
+	}
+	if len(failed) > 0 {
+		return newMultiError(failed)
 	}
 	return nil
 }
 
-func runParallel(contexts, kubectlArgs []string, timeout time.Duration, header string, out, errOut io.Writer) error {
-	results := make([]result, len(contexts))
-	var wg sync.WaitGroup
-	for i, ctxName := range contexts {
-		wg.Add(1)
-		go func(i int, ctxName string) {
-			defer wg.Done()
-			ctx, cancel := maybeWithTimeout(timeout)
-			defer cancel()
-			results[i] = runInContext(ctx, ctxName, kubectlArgs)
-		}(i, ctxName)
+func runParallel(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	var onDone func(result)
+	var groups groupHeaders
+	var next int
+	switch {
+	case opts.streamOrdered:
+		// Hold results that finish early until those before them are printed.
+		pending := map[int]result{}
+		onDone = func(r result) {
+			pending[r.index] = r
+			for p, ok := pending[next]; ok; p, ok = pending[next] {
+				delete(pending, next)
+				groups.print(p, opts, out)
+				printResult(p, opts, out, errOut)
+				next++
+			}
+		}
+	case opts.output == outputJSONL:
+		// Emit each record as soon as its context finishes, not in input order.
+		onDone = func(r result) { printResult(r, opts, out, errOut) }
+	}
+	results, aborted := runContexts(ctx, contexts, kubectlArgs, opts, true, onDone)
+	unrun := notRun(contexts, results)
+	results = slices.DeleteFunc(results, func(r result) bool { return r.ctxName == "" })
+	if opts.streamOrdered {
+		// Contexts that never started leave gaps; print what waits behind them.
+		for _, r := range results {
+			if r.index >= next {
+				groups.print(r, opts, out)
+				printResult(r, opts, out, errOut)
+			}
+		}
+	}
+	sortResults(results, opts.sortBy)
+	if opts.groupBy != nil {
+		groupResults(results, opts.groupBy)
 	}
-	wg.Wait()
 
-	var failed int
+	var failed []result
 	for _, r := range results {
-		printResult(r, header, out, errOut)
-		if r.err != nil {
-			failed++
+		if onDone == nil {
+			groups.print(r, opts, out)
+			printResult(r, opts, out, errOut)
+		}
+		if r.failed() {
+			failed = append(failed, r)
 		}
 	}
-	if failed > 0 {
-		return fmt.Errorf("%d context(s) failed", failed)
+	if interruptedRun(ctx) {
+		return interrupted(errOut)
+	}
+	if aborted && opts.failFast {
+		return fmt.Errorf("stopped after the first failure, cancelling remaining contexts (%w)", newMultiError(failed))
+	}
+	if aborted {
+		return fmt.Errorf("stopped after %d failure(s), cancelling remaining contexts (%w)", opts.abortAfter, newMultiError(failed))
+	}
+	if len(unrun) > 0 {
+		return notStarted(unrun, failed, opts, errOut)
+	}
+	if len(failed) > 0 {
+		return newMultiError(failed)
 	}
 	return nil
 }
 
-func maybeWithTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+// runContexts runs kubectlArgs in every context, at once with parallel or
+// otherwise one after another, and returns the results in input order. It
+// reports whether --fail-fast or --max-failures stopped the run early.
+// onDone, if not nil, is called with each result as its context finishes,
+// one call at a time. Contexts that never started, because the run was
+// stopped, interrupted or out of --deadline, have a zero result, as do those
+// still running when --fail-fast or --max-failures cancelled them. The
+// others are added to opts.finished for --timings and --summary-json.
+func runContexts(ctx context.Context, contexts, kubectlArgs []string, opts *options, parallel bool, onDone func(result)) (results []result, stopped bool) {
+	stopAfter := opts.abortAfter
+	if opts.failFast {
+		stopAfter = 1
+	}
+	// limiter spaces out kubectl starts for --rate.
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if opts.rate > 0 && parallel {
+		limiter = rate.NewLimiter(rate.Limit(opts.rate), 1)
+	}
+
+	results = make([]result, len(contexts))
+	stopped = xctx.ForEach(ctx, contexts, xctx.ForEachOptions{
+		Parallel:  parallel,
+		StopAfter: stopAfter,
+		Done: func(i int) {
+			if onDone != nil && results[i].ctxName != "" {
+				onDone(results[i])
+			}
+		},
+	}, func(runCtx context.Context, i int, ctxName string) bool {
+		if limiter.Wait(runCtx) != nil || runCtx.Err() != nil || deadlineReached(runCtx, opts) {
+			return false
+		}
+		r := runRecovered(runCtx, ctxName, kubectlArgs, opts)
+		if r.failed() && runCtx.Err() != nil && ctx.Err() == nil {
+			// Cancelled because another context failed: it did not fail
+			// itself, so leave it as not run.
+			return false
+		}
+		results[i] = r
+		results[i].index, results[i].total = i, len(contexts)
+		return results[i].failed()
+	})
+	if opts.finished != nil {
+		for _, r := range results {
+			if r.ctxName != "" {
+				*opts.finished = append(*opts.finished, r)
+			}
+		}
+	}
+	return results, stopped
+}
+
+// notRun returns the contexts that runContexts never started.
+func notRun(contexts []string, results []result) []string {
+	var unrun []string
+	for i, r := range results {
+		if r.ctxName == "" {
+			unrun = append(unrun, contexts[i])
+		}
+	}
+	return unrun
+}
+
+// Values accepted by --sort-output.
+const (
+	sortInput    = "input"
+	sortDuration = "duration"
+	sortStatus   = "status"
+	sortName     = "name"
+)
+
+var validSortOrders = map[string]bool{sortInput: true, sortDuration: true, sortStatus: true, sortName: true}
+
+// sortResults reorders parallel results for printing. The sort is stable, so
+// results with equal keys keep their input order.
+func sortResults(results []result, by string) {
+	switch by {
+	case sortDuration:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].duration < results[j].duration })
+	case sortStatus:
+		sort.SliceStable(results, func(i, j int) bool { return !results[i].failed() && results[j].failed() })
+	case sortName:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].ctxName < results[j].ctxName })
+	}
+}
+
+// interrupted reports an interrupted run and returns errInterrupted.
+func interrupted(errOut io.Writer) error {
+	_, _ = fmt.Fprintln(errOut, "[xctx] interrupted")
+	return errInterrupted
+}
+
+// kubeconfigFor returns the kubeconfig file of the first --kubeconfig-map
+// entry matching ctxName, or "" to use the shared one.
+func kubeconfigFor(ctxName string, opts *options) string {
+	for _, rule := range opts.kubeconfigMap {
+		if rule.re.MatchString(ctxName) {
+			return rule.value
+		}
+	}
+	return ""
+}
+
+// timeoutFor returns the timeout for ctxName: the first matching
+// --timeout-map entry, or else --timeout.
+func timeoutFor(ctxName string, opts *options) time.Duration {
+	for _, rule := range opts.timeoutMap {
+		if rule.re.MatchString(ctxName) {
+			// Validated by prepareOptions.
+			d, _ := time.ParseDuration(rule.value)
+			return d
+		}
+	}
+	return opts.timeout
+}
+
+func maybeWithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
 	if d > 0 {
-		return context.WithTimeout(context.Background(), d)
+		return context.WithTimeout(parent, d)
 	}
-	return context.Background(), func() {}
+	return parent, func() {}
 }
@@ -4,8 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +22,14 @@ import (
 
 // mockKubectl replaces kubectlRunner for the duration of the test.
 func mockKubectl(t *testing.T, fn func(ctx context.Context, args ...string) ([]byte, []byte, error)) {
+	t.Helper()
+	mockKubectlExec(t, func(ctx context.Context, _ execConfig, args ...string) ([]byte, []byte, error) {
+		return fn(ctx, args...)
+	})
+}
+
+// mockKubectlExec is like mockKubectl for tests that inspect the execConfig.
+func mockKubectlExec(t *testing.T, fn func(ctx context.Context, cfg execConfig, args ...string) ([]byte, []byte, error)) {
 	t.Helper()
 	orig := kubectlRunner
 	kubectlRunner = fn
@@ -38,11 +54,111 @@ func useFakeKubectl(t *testing.T) {
 	})
 }
 
+// runCmd executes the xctx command with args, returning its stdout, stderr
+// and error.
+func runCmd(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	// Keep the user's own config file and context cache out of tests.
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var out, errOut strings.Builder
+	cmd := newCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), errOut.String(), err
+}
+
+// useFailingKubectl installs a mock that lists fakeContextList but fails every
+// command run against a context, returning a pointer to the call count.
+func useFailingKubectl(t *testing.T) *int {
+	t.Helper()
+	return &useContextKubectl(t, map[string]fakeResponse{"": {err: errors.New("connection refused")}}).total
+}
+
+// fakeResponse is the fake kubectl's answer to a command. In stdout,
+// "{context}" is replaced with the context's name and "{call}" with the
+// number of commands run so far across contexts.
+type fakeResponse struct {
+	stdout string
+	stderr string
+	err    error
+	// delay holds the answer back. A command cancelled while it waits
+	// answers at once with err, or else with the cancellation error.
+	delay time.Duration
+	// nth replaces the answer to the context's nth command, counting from 1.
+	nth map[int]fakeResponse
+}
+
+// fakeKubectl records the calls made to the fake installed by
+// useContextKubectl.
+type fakeKubectl struct {
+	mu       sync.Mutex
+	calls    map[string]int // commands run per context, and per "config <subcommand>"
+	total    int            // commands run across contexts
+	commands []string       // "<kubectl> <args> <env>" per command run in a context, in order
+}
+
+// useContextKubectl installs a fake kubectl that answers a command in a
+// context with responses[context], or with responses[""] for the contexts
+// not in the map. "kubectl config <subcommand>" is answered with
+// responses["config <subcommand>"], by default listing fakeContextList.
+func useContextKubectl(t *testing.T, responses map[string]fakeResponse) *fakeKubectl {
+	t.Helper()
+	f := &fakeKubectl{calls: map[string]int{}}
+	mockKubectlExec(t, func(ctx context.Context, cfg execConfig, args ...string) ([]byte, []byte, error) {
+		f.mu.Lock()
+		key := args[1]
+		if args[0] == "config" {
+			key = "config " + args[1]
+		}
+		f.calls[key]++
+		r, ok := responses[key]
+		switch {
+		case args[0] == "config" && !ok:
+			r = fakeResponse{stdout: fakeContextList}
+		case args[0] != "config":
+			f.total++
+			f.commands = append(f.commands, strings.TrimSpace(cfg.binary()+" "+strings.Join(args, " ")+" "+strings.Join(cfg.env, ",")))
+			if !ok {
+				r = responses[""]
+			}
+		}
+		if nth, ok := r.nth[f.calls[key]]; ok {
+			r = nth
+		}
+		stdout := strings.NewReplacer("{context}", args[1], "{call}", strconv.Itoa(f.total)).Replace(r.stdout)
+		f.mu.Unlock()
+
+		if r.delay > 0 {
+			select {
+			case <-time.After(r.delay):
+			case <-ctx.Done():
+				if r.err != nil {
+					return nil, nil, r.err
+				}
+				return nil, nil, ctx.Err()
+			}
+		}
+		var stderr []byte
+		if r.stderr != "" {
+			stderr = []byte(r.stderr)
+		}
+		return []byte(stdout), stderr, r.err
+	})
+	return f
+}
+
+// untilCancelled answers commands in every context only once they are
+// cancelled.
+var untilCancelled = map[string]fakeResponse{"": {delay: time.Hour}}
+
 // --- matchingContexts ---
 
 func TestMatchingContexts_AllMatch(t *testing.T) {
 	useFakeKubectl(t)
-	got, err := matchingContexts(regexp.MustCompile("."))
+	got, err := matchingContexts(regexp.MustCompile("."), execConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -53,7 +169,7 @@ func TestMatchingContexts_AllMatch(t *testing.T) {
 
 func TestMatchingContexts_FilterByPattern(t *testing.T) {
 	useFakeKubectl(t)
-	got, err := matchingContexts(regexp.MustCompile("prod"))
+	got, err := matchingContexts(regexp.MustCompile("prod"), execConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -69,7 +185,7 @@ func TestMatchingContexts_FilterByPattern(t *testing.T) {
 
 func TestMatchingContexts_NoMatch(t *testing.T) {
 	useFakeKubectl(t)
-	got, err := matchingContexts(regexp.MustCompile("nonexistent"))
+	got, err := matchingContexts(regexp.MustCompile("nonexistent"), execConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -79,21 +195,246 @@ func TestMatchingContexts_NoMatch(t *testing.T) {
 }
 
 func TestMatchingContexts_KubectlError(t *testing.T) {
-	mockKubectl(t, func(_ context.Context, _ ...string) ([]byte, []byte, error) {
-		return nil, nil, errors.New("kubectl not found")
-	})
-	_, err := matchingContexts(regexp.MustCompile("."))
+	useContextKubectl(t, map[string]fakeResponse{"config get-contexts": {err: errors.New("kubectl not found")}})
+	_, err := matchingContexts(regexp.MustCompile("."), execConfig{})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
 
+func TestMatchingContexts_KubectlNotInstalled(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"config get-contexts": {err: &exec.Error{Name: "kubectl", Err: exec.ErrNotFound}}})
+	_, err := matchingContexts(regexp.MustCompile("."), execConfig{})
+	want := "kubectl not found on PATH; install it or set --kubectl-bin"
+	if err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+func TestKubectlBin_UsedForEveryCall(t *testing.T) {
+	var bins []string
+	mockKubectlExec(t, func(_ context.Context, cfg execConfig, args ...string) ([]byte, []byte, error) {
+		bins = append(bins, cfg.binary())
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		return nil, nil, nil
+	})
+	if _, _, err := runCmd(t, "--kubectl-bin", "/opt/kubectl-1.30", "staging", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "/opt/kubectl-1.30 /opt/kubectl-1.30"
+	if strings.Join(bins, " ") != want {
+		t.Errorf("want binaries %v, got %v", want, bins)
+	}
+}
+
+// --- shuffleContexts ---
+
+func TestShuffleContexts_FixedSeedIsDeterministic(t *testing.T) {
+	input := strings.Split(fakeContextList, "\n")
+	first := append([]string(nil), input...)
+	second := append([]string(nil), input...)
+	shuffleContexts(first, 42)
+	shuffleContexts(second, 42)
+	if strings.Join(first, ",") != strings.Join(second, ",") {
+		t.Errorf("same seed produced different orders: %v vs %v", first, second)
+	}
+	if strings.Join(first, ",") == strings.Join(input, ",") {
+		t.Errorf("expected seed 42 to reorder the contexts, got %v", first)
+	}
+	seen := map[string]bool{}
+	for _, c := range first {
+		seen[c] = true
+	}
+	if len(seen) != len(input) {
+		t.Errorf("shuffle must be a permutation of the input, got %v", first)
+	}
+}
+
+func TestExecute_ShuffleWithSeed(t *testing.T) {
+	useFakeKubectl(t)
+	out1, _, err := runCmd(t, "--shuffle", "--seed", "7", "--list", ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out2, _, _ := runCmd(t, "--shuffle", "--seed", "7", "--list", ".")
+	if out1 != out2 {
+		t.Errorf("expected identical order for the same seed:\n%s\nvs\n%s", out1, out2)
+	}
+}
+
+func TestExecute_ShuffleNotWithSortOutput(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{})
+	_, _, err := runCmd(t, "--parallel", "--shuffle", "--sort-output", "name", ".", "get", "pods")
+	if !errors.Is(err, ErrUsage) {
+		t.Errorf("want a usage error, got %v", err)
+	}
+	if fake.total != 0 {
+		t.Errorf("expected nothing to run, got %q", fake.commands)
+	}
+}
+
+// --- --sample ---
+
+func TestSampleContexts_FixedSeedIsDeterministic(t *testing.T) {
+	input := strings.Split(fakeContextList, "\n")
+	first := sampleContexts(input, 50, 42)
+	second := sampleContexts(input, 50, 42)
+	if len(first) != 2 || strings.Join(first, ",") != strings.Join(second, ",") {
+		t.Errorf("want the same 2 contexts for seed 42, got %v and %v", first, second)
+	}
+	if got := sampleContexts(input, 1, 42); len(got) != 1 {
+		t.Errorf("expected a small percentage to round up to one context, got %v", got)
+	}
+	if got := sampleContexts(input, 100, 42); strings.Join(got, ",") != strings.Join(input, ",") {
+		t.Errorf("expected 100%% to keep every context in order, got %v", got)
+	}
+}
+
+func TestExecute_SampleWithSeed(t *testing.T) {
+	useFakeKubectl(t)
+	path := filepath.Join(t.TempDir(), "summary.json")
+	out, errOut, err := runCmd(t, "--sample", "50", "--seed", "7", "--summary-json", path, ".", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut, "[xctx] sampled 2 of 4 matched context(s)\n") {
+		t.Errorf("expected the sample to be reported, got %q", errOut)
+	}
+	var ran []string
+	for _, c := range sampleContexts(strings.Split(fakeContextList, "\n"), 50, 7) {
+		ran = append(ran, "### Context: "+c)
+	}
+	var got []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "### Context: ") {
+			got = append(got, line)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(ran, ",") {
+		t.Errorf("want the seeded sample %q, got %q", ran, got)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), `"matched": 4`) {
+		t.Errorf("expected the summary to note 4 matched contexts, got %s (%v)", data, err)
+	}
+}
+
+func TestSample_Validation(t *testing.T) {
+	useFakeKubectl(t)
+	_, _, err := runCmd(t, "--sample", "150", ".", "get", "pods")
+	if err == nil || err.Error() != "--sample must be between 0 and 100" {
+		t.Errorf("want a range error, got %v", err)
+	}
+}
+
+// --- moveCurrentFirst ---
+
+func TestCurrentFirst_MovesCurrentContextToFront(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"config current-context": {stdout: "staging-us\n"}})
+	out, _, err := runCmd(t, "--current-first", "--list", ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "staging-us\nprod-us-east\nprod-eu-west\ndev-local\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestCurrentFirst_NoCurrentContextKeepsOrder(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"config current-context": {stderr: "error: current-context is not set\n", err: errors.New("exit status 1")},
+	})
+	out, _, err := runCmd(t, "--current-first", "--list", "prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "prod-us-east\nprod-eu-west\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+// --- compilePattern ---
+
+func TestCompilePattern_IgnoreCase(t *testing.T) {
+	useFakeKubectl(t)
+	re, err := compilePattern("PROD", &options{ignoreCase: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := matchingContexts(re, execConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "prod-us-east" {
+		t.Errorf("want both prod contexts, got %v", got)
+	}
+}
+
+func TestCompilePattern_Exact(t *testing.T) {
+	useFakeKubectl(t)
+	for pattern, want := range map[string]int{"prod": 0, "prod-us-east": 1, "prod-.*": 2} {
+		re, err := compilePattern(pattern, &options{exact: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := matchingContexts(re, execConfig{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != want {
+			t.Errorf("--exact %q: want %d contexts, got %v", pattern, want, got)
+		}
+	}
+}
+
+func TestCompilePattern_ExactIgnoreCase(t *testing.T) {
+	re, err := compilePattern("PROD-US-EAST|dev", &options{exact: true, ignoreCase: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !re.MatchString("prod-us-east") || re.MatchString("dev-local") {
+		t.Errorf("expected a whole-name, case-insensitive match, got %s", re)
+	}
+}
+
+func TestCompilePattern_CaseSensitiveByDefault(t *testing.T) {
+	re, err := compilePattern("PROD", &options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if re.MatchString("prod-us-east") {
+		t.Error("expected case-sensitive match by default")
+	}
+}
+
+func TestAnyOf_MatchesEachFamily(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--any-of", "prod", "--any-of", "staging", "--list")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "prod-us-east\nprod-eu-west\nstaging-us\n" {
+		t.Errorf("want the prod and staging contexts, got %q", out)
+	}
+}
+
+func TestAnyOfPattern_Literal(t *testing.T) {
+	re, err := compilePattern(anyOfPattern([]string{"eks.us-east-1", "a|b"}), &options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !re.MatchString("eks.us-east-1") || re.MatchString("eksXus-east-1") || re.MatchString("a") {
+		t.Errorf("expected the values to match literally, got %s", re)
+	}
+}
+
 // --- printResult ---
 
 func TestPrintResult_DefaultHeader(t *testing.T) {
 	var out, errOut strings.Builder
 	r := result{ctxName: "prod-us-east", stdout: []byte("pod/foo\n")}
-	printResult(r, "### Context: {context}", &out, &errOut)
+	printResult(r, &options{header: "### Context: {context}"}, &out, &errOut)
 
 	if !strings.Contains(out.String(), "### Context: prod-us-east") {
 		t.Errorf("expected header in output, got: %q", out.String())
@@ -110,7 +451,7 @@ func TestPrintResult_DefaultHeader(t *testing.T) {
 func TestPrintResult_CustomHeader(t *testing.T) {
 	var out, errOut strings.Builder
 	r := result{ctxName: "staging", stdout: []byte("output\n")}
-	printResult(r, "=== {context} ===", &out, &errOut)
+	printResult(r, &options{header: "=== {context} ==="}, &out, &errOut)
 
 	if !strings.Contains(out.String(), "=== staging ===") {
 		t.Errorf("expected custom header, got: %q", out.String())
@@ -120,7 +461,7 @@ func TestPrintResult_CustomHeader(t *testing.T) {
 func TestPrintResult_NoHeader(t *testing.T) {
 	var out, errOut strings.Builder
 	r := result{ctxName: "prod", stdout: []byte("{\"items\":[]}\n")}
-	printResult(r, "", &out, &errOut)
+	printResult(r, &options{header: ""}, &out, &errOut)
 
 	if strings.Contains(out.String(), "prod") {
 		t.Errorf("expected no header, but found context name in output: %q", out.String())
@@ -134,7 +475,7 @@ func TestPrintResult_NoHeader(t *testing.T) {
 func TestPrintResult_StderrPropagated(t *testing.T) {
 	var out, errOut strings.Builder
 	r := result{ctxName: "prod", stderr: []byte("Error from server\n"), err: errors.New("exit status 1")}
-	printResult(r, "### Context: {context}", &out, &errOut)
+	printResult(r, &options{header: "### Context: {context}"}, &out, &errOut)
 
 	if !strings.Contains(errOut.String(), "Error from server") {
 		t.Errorf("expected stderr content, got: %q", errOut.String())
@@ -144,164 +485,905 @@ func TestPrintResult_StderrPropagated(t *testing.T) {
 	}
 }
 
-// --- execute ---
+func TestPrintResult_AliasedHeader(t *testing.T) {
+	aliases, err := parseAliases([]string{"arn:aws:eks:.*:cluster/prod=Production"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out, errOut strings.Builder
+	r := result{ctxName: "arn:aws:eks:us-east-1:123:cluster/prod", stdout: []byte("ok\n")}
+	printResult(r, &options{header: "{context} ({realcontext})", aliases: aliases}, &out, &errOut)
 
-func TestExecute_InvalidRegex(t *testing.T) {
-	err := execute("[invalid", nil, false, false, 0, false, "")
-	if err == nil {
-		t.Fatal("expected error for invalid regex, got nil")
+	want := "Production (arn:aws:eks:us-east-1:123:cluster/prod)"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("expected header %q, got: %q", want, out.String())
 	}
 }
 
-func TestExecute_NoMatch(t *testing.T) {
+func TestPrintResult_PrefixLines(t *testing.T) {
+	var out, errOut strings.Builder
+	r := result{ctxName: "prod-us-east", stdout: []byte("NAME READY\npod/foo 1/1\n")}
+	printResult(r, &options{header: "### Context: {context}", prefixLines: true}, &out, &errOut)
+
+	want := "prod-us-east\tNAME READY\nprod-us-east\tpod/foo 1/1\n"
+	if out.String() != want {
+		t.Errorf("expected every line prefixed and no header:\nwant %q\ngot  %q", want, out.String())
+	}
+}
+
+func TestHeader_ExpandsEnv(t *testing.T) {
 	useFakeKubectl(t)
-	err := execute("nonexistent", []string{"get", "pods"}, false, false, 0, false, "### Context: {context}")
+	t.Setenv("XCTX_BUILD_ID", "build-42")
+	out, _, err := runCmd(t, "--header", "[${XCTX_BUILD_ID}] {context}${XCTX_UNSET}", "prod-us-east", "get", "pods")
 	if err != nil {
-		t.Errorf("expected nil error for no-match case, got: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "[build-42] prod-us-east\n") {
+		t.Errorf("expected env var expanded in header, got: %q", out)
 	}
 }
 
-func TestExecute_NoCommand(t *testing.T) {
+func TestSeparator_Custom(t *testing.T) {
 	useFakeKubectl(t)
-	err := execute("prod", nil, false, false, 0, false, "### Context: {context}")
-	if err == nil {
-		t.Fatal("expected error when no kubectl command given, got nil")
+	out, _, err := runCmd(t, "--separator", `---\n`, "--header", "{context}", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "prod-us-east\nresult from prod-us-east\n---\nprod-eu-west\nresult from prod-eu-west\n---\n"
+	if out != want {
+		t.Errorf("want %q, got %q", want, out)
 	}
 }
 
-func TestExecute_List(t *testing.T) {
+func TestSeparator_EmptyAndWithoutHeader(t *testing.T) {
 	useFakeKubectl(t)
-	var out strings.Builder
-	// Intercept stdout by temporarily replacing — test list via matchingContexts directly
-	re := regexp.MustCompile("prod")
-	contexts, err := matchingContexts(re)
+	out, _, err := runCmd(t, "--separator", "", "prod", "get", "pods")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	for _, c := range contexts {
-		out.WriteString(c + "\n")
+	if strings.Contains(out, "\n\n") {
+		t.Errorf("expected no blank separator lines, got %q", out)
 	}
-	if !strings.Contains(out.String(), "prod-us-east") || !strings.Contains(out.String(), "prod-eu-west") {
-		t.Errorf("unexpected list output: %q", out.String())
+
+	out, _, err = runCmd(t, "--separator", `\n`, "--header", "", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "result from prod-us-east\n\nresult from prod-eu-west\n\n"; out != want {
+		t.Errorf("expected the separator even without a header, want %q, got %q", want, out)
 	}
 }
 
-// --- runSequential ---
-
-func TestRunSequential_AllSucceed(t *testing.T) {
+func TestSmartHeader_OmittedForSingleContext(t *testing.T) {
 	useFakeKubectl(t)
-	var out, errOut strings.Builder
-	err := runSequential([]string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, 0, false, "### Context: {context}", &out, &errOut)
+	out, _, err := runCmd(t, "--smart-header", "staging", "get", "pods")
 	if err != nil {
-		t.Errorf("expected nil, got: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(out.String(), "prod-us-east") || !strings.Contains(out.String(), "prod-eu-west") {
-		t.Errorf("expected both contexts in output, got: %q", out.String())
+	if out != "result from staging-us\n" {
+		t.Errorf("expected bare output for a single context, got %q", out)
 	}
-}
 
-func TestRunSequential_CountsFailures(t *testing.T) {
-	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
-		if args[0] == "config" {
-			return []byte(fakeContextList), nil, nil
-		}
-		return nil, nil, errors.New("connection refused")
-	})
-	var out, errOut strings.Builder
-	err := runSequential([]string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, 0, false, "", &out, &errOut)
-	if err == nil {
-		t.Fatal("expected error for failed contexts, got nil")
+	out, _, err = runCmd(t, "--smart-header", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(err.Error(), "2") {
-		t.Errorf("expected failure count in error, got: %v", err)
+	if strings.Count(out, "### Context: ") != 2 {
+		t.Errorf("expected headers kept for multiple contexts, got %q", out)
 	}
 }
 
-func TestRunSequential_FailFast(t *testing.T) {
-	callCount := 0
-	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
-		if args[0] == "config" {
-			return []byte(fakeContextList), nil, nil
-		}
-		callCount++
-		return nil, nil, errors.New("connection refused")
-	})
-	var out, errOut strings.Builder
-	err := runSequential([]string{"ctx-a", "ctx-b", "ctx-c"}, []string{"get", "pods"}, 0, true, "", &out, &errOut)
-	if err == nil {
-		t.Fatal("expected error, got nil")
-	}
-	if callCount != 1 {
-		t.Errorf("fail-fast should stop after first failure, but kubectl was called %d times", callCount)
+func TestRunInContext_EnvForwarded(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	runInContext(context.Background(), "prod-us-east", []string{"get", "pods"}, &options{env: []string{"AWS_PROFILE=prod"}})
+	if want := "kubectl --context prod-us-east get pods AWS_PROFILE=prod"; strings.Join(fake.commands, "|") != want {
+		t.Errorf("expected the env pair passed to kubectl, got %q", fake.commands)
 	}
 }
 
-// --- runParallel ---
-
-func TestRunParallel_AllSucceed(t *testing.T) {
-	useFakeKubectl(t)
-	var out, errOut strings.Builder
-	err := runParallel([]string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, 0, "### Context: {context}", &out, &errOut)
+func TestRunParallel_EnvMapPerContext(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	rules, err := parseContextRules("env-map", []string{"^prod-us=AWS_PROFILE=prod-us", "^prod-eu=AWS_PROFILE=prod-eu"})
 	if err != nil {
-		t.Errorf("expected nil, got: %v", err)
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := &options{env: []string{"AWS_PROFILE=default", "AWS_REGION=us-east-1"}, envMap: rules}
+	var out, errOut strings.Builder
+	if err := runParallel(context.Background(), []string{"prod-us-east", "prod-eu-west", "dev-local"}, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"kubectl --context prod-us-east get pods AWS_PROFILE=default,AWS_REGION=us-east-1,AWS_PROFILE=prod-us",
+		"kubectl --context prod-eu-west get pods AWS_PROFILE=default,AWS_REGION=us-east-1,AWS_PROFILE=prod-eu",
+		"kubectl --context dev-local get pods AWS_PROFILE=default,AWS_REGION=us-east-1",
+	} {
+		if !slices.Contains(fake.commands, want) {
+			t.Errorf("want %q to run, got %q", want, fake.commands)
+		}
 	}
 }
 
-func TestRunParallel_CountsFailures(t *testing.T) {
-	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
-		if args[0] == "config" {
-			return []byte(fakeContextList), nil, nil
-		}
-		return nil, nil, errors.New("connection refused")
-	})
-	var out, errOut strings.Builder
-	err := runParallel([]string{"ctx-a", "ctx-b"}, []string{"get", "pods"}, 0, "", &out, &errOut)
-	if err == nil {
-		t.Fatal("expected error, got nil")
+func TestKubeconfigMap_PassesMappedFile(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	_, _, err := runCmd(t, "--parallel", "--kubeconfig-map", "^prod-eu=/etc/kube/eu.yaml", "--kubeconfig-map", "^prod=/etc/kube/prod.yaml", "prod|dev", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(err.Error(), "2") {
-		t.Errorf("expected failure count in error, got: %v", err)
+	for _, want := range []string{
+		"kubectl --context prod-us-east --kubeconfig=/etc/kube/prod.yaml get pods",
+		"kubectl --context prod-eu-west --kubeconfig=/etc/kube/eu.yaml get pods",
+		"kubectl --context dev-local get pods",
+	} {
+		if !slices.Contains(fake.commands, want) {
+			t.Errorf("want %q to run, got %q", want, fake.commands)
+		}
 	}
 }
 
-func TestRunParallel_OutputOrdering(t *testing.T) {
-	// Parallel runs must print results in input order, not arrival order.
-	// Simulate varying latency: first context is slower.
-	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+func TestKubeconfigMap_ListsContextsFromMappedFiles(t *testing.T) {
+	ran := map[string]string{}
+	mockKubectlExec(t, func(_ context.Context, cfg execConfig, args ...string) ([]byte, []byte, error) {
 		if args[0] == "config" {
+			// Only the merged view, with the mapped file, has the edge context.
+			if strings.Join(cfg.env, ",") == "KUBECONFIG=/etc/kube/config:/etc/kube/edge.yaml" {
+				return []byte(fakeContextList + "\nedge-1"), nil, nil
+			}
 			return []byte(fakeContextList), nil, nil
 		}
-		if args[1] == "slow-ctx" {
-			time.Sleep(20 * time.Millisecond)
-		}
-		return []byte("result from " + args[1] + "\n"), nil, nil
+		ran[args[1]] = strings.Join(args[2:], " ")
+		return nil, nil, nil
 	})
-	var out, errOut strings.Builder
-	err := runParallel([]string{"slow-ctx", "fast-ctx"}, []string{"get", "pods"}, 0, "### Context: {context}", &out, &errOut)
+	_, _, err := runCmd(t, "--no-cache", "--kubeconfig", "/etc/kube/config", "--kubeconfig-map", "^edge=/etc/kube/edge.yaml", "edge|dev", "get", "pods")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	slowIdx := strings.Index(out.String(), "slow-ctx")
-	fastIdx := strings.Index(out.String(), "fast-ctx")
-	if slowIdx > fastIdx {
-		t.Errorf("expected slow-ctx before fast-ctx (input order), but output was:\n%s", out.String())
+	if ran["edge-1"] != "--kubeconfig=/etc/kube/edge.yaml get pods" || ran["dev-local"] != "get pods" {
+		t.Errorf("expected the mapped file's context to be matched and run with it, got %q", ran)
 	}
 }
 
-// --- maybeWithTimeout ---
-
-func TestMaybeWithTimeout_Zero(t *testing.T) {
-	ctx, cancel := maybeWithTimeout(0)
-	defer cancel()
-	select {
-	case <-ctx.Done():
-		t.Error("context should not be done with zero timeout")
-	default:
+func TestParseContextRules_Invalid(t *testing.T) {
+	for _, v := range []string{"no-separator", "=value", "[bad=value"} {
+		if _, err := parseContextRules("env-map", []string{v}); err == nil {
+			t.Errorf("expected error for %q, got nil", v)
+		}
 	}
 }
 
-func TestMaybeWithTimeout_NonZero(t *testing.T) {
-	ctx, cancel := maybeWithTimeout(1 * time.Millisecond)
+func TestKubectlRunner_ChildSeesEnv(t *testing.T) {
+	// Point kubectlRunner at a fake "kubectl" that prints its environment.
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"profile=$AWS_PROFILE\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	out, _, err := kubectlRunner(context.Background(), execConfig{env: []string{"AWS_PROFILE=staging"}}, "version")
+	if err != nil {
+		t.Skipf("cannot run fake kubectl: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "profile=staging" {
+		t.Errorf("expected child to see AWS_PROFILE=staging, got %q", out)
+	}
+}
+
+// --- withSince ---
+
+func TestWithSince_OnlyForLogs(t *testing.T) {
+	opts := &options{since: time.Hour}
+	var errOut strings.Builder
+	got := withSince([]string{"logs", "deploy/app", "-c", "app"}, opts, &errOut)
+	if strings.Join(got, " ") != "logs deploy/app -c app --since=1h0m0s" {
+		t.Errorf("expected --since appended for logs, got %v", got)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("expected no warning for logs, got: %q", errOut.String())
+	}
+
+	got = withSince([]string{"get", "pods"}, opts, &errOut)
+	if strings.Join(got, " ") != "get pods" {
+		t.Errorf("expected args unchanged for get, got %v", got)
+	}
+	if !strings.Contains(errOut.String(), "--since only applies to logs") {
+		t.Errorf("expected warning for non-logs verb, got: %q", errOut.String())
+	}
+}
+
+func TestWithSince_SkipsLeadingFlagsAndValues(t *testing.T) {
+	var errOut strings.Builder
+	for _, args := range [][]string{
+		{"--v=6", "logs", "pod"},
+		{"-n", "web", "logs", "deploy/x"},
+	} {
+		got := withSince(args, &options{since: time.Hour}, &errOut)
+		if got[len(got)-1] != "--since=1h0m0s" {
+			t.Errorf("%q: expected --since appended for logs, got %v", args, got)
+		}
+	}
+	if errOut.Len() > 0 {
+		t.Errorf("expected no warning, got %q", errOut.String())
+	}
+}
+
+// --- newLogger ---
+
+func TestRunInContext_DebugLogsCommand(t *testing.T) {
+	useFakeKubectl(t)
+	var logs strings.Builder
+	logger, err := newLogger(&logs, "debug", "text")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runInContext(context.Background(), "prod-us-east", []string{"get", "pods"}, &options{as: "admin", logger: logger})
+	if !strings.Contains(logs.String(), `command="kubectl --context prod-us-east --as=admin get pods"`) {
+		t.Errorf("expected constructed command in debug log, got: %q", logs.String())
+	}
+}
+
+func TestNewLogger_JSONAtInfo(t *testing.T) {
+	var logs strings.Builder
+	logger, err := newLogger(&logs, "info", "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Info("shown", "context", "prod")
+	if strings.Contains(logs.String(), "hidden") {
+		t.Errorf("debug record should be filtered at info level, got: %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), `"msg":"shown","context":"prod"`) {
+		t.Errorf("expected JSON record, got: %q", logs.String())
+	}
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	if _, err := newLogger(io.Discard, "verbose", "text"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, err := newLogger(io.Discard, "info", "xml"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+}
+
+func TestLogs_StayOffStdout(t *testing.T) {
+	useFakeKubectl(t)
+	out, errOut, err := runCmd(t, "--log-level", "debug", "--header", "", "prod-us-east", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "result from prod-us-east\n" {
+		t.Errorf("expected only kubectl output on stdout, got: %q", out)
+	}
+	if !strings.Contains(errOut, "running kubectl") {
+		t.Errorf("expected debug logs on stderr, got: %q", errOut)
+	}
+}
+
+// --- parseAliases ---
+
+func TestParseAliases_ExactNameOnly(t *testing.T) {
+	aliases, err := parseAliases([]string{"prod-us-east=US"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := &options{aliases: aliases}
+	if got := displayName("prod-us-east", opts); got != "US" {
+		t.Errorf("want alias US, got %q", got)
+	}
+	if got := displayName("prod-us-east-2", opts); got != "prod-us-east-2" {
+		t.Errorf("alias should match the whole name only, got %q", got)
+	}
+}
+
+func TestParseAliases_Invalid(t *testing.T) {
+	for _, v := range []string{"no-separator", "=Name", "[bad=Name"} {
+		if _, err := parseAliases([]string{v}); err == nil {
+			t.Errorf("expected error for alias %q, got nil", v)
+		}
+	}
+}
+
+// --- execute ---
+
+func TestExecute_InvalidRegex(t *testing.T) {
+	err := execute(context.Background(), "[invalid", nil, &options{}, io.Discard, io.Discard)
+	if err == nil {
+		t.Fatal("expected error for invalid regex, got nil")
+	}
+}
+
+func TestExecute_NoMatch(t *testing.T) {
+	useFakeKubectl(t)
+	err := execute(context.Background(), "nonexistent", []string{"get", "pods"}, &options{header: "### Context: {context}"}, io.Discard, io.Discard)
+	if err != nil {
+		t.Errorf("expected nil error for no-match case, got: %v", err)
+	}
+}
+
+func TestExecute_FailOnEmpty(t *testing.T) {
+	useFakeKubectl(t)
+	err := execute(context.Background(), "nonexistent", []string{"get", "pods"}, &options{failOnEmpty: true}, io.Discard, io.Discard)
+	if err == nil {
+		t.Fatal("expected error with failOnEmpty, got nil")
+	}
+	if !strings.Contains(err.Error(), `"nonexistent"`) {
+		t.Errorf("expected the pattern in the error, got: %v", err)
+	}
+	if err := execute(context.Background(), "prod", []string{"get", "pods"}, &options{failOnEmpty: true}, io.Discard, io.Discard); err != nil {
+		t.Errorf("expected no error when contexts match, got: %v", err)
+	}
+}
+
+func TestExecute_NoCommand(t *testing.T) {
+	useFakeKubectl(t)
+	err := execute(context.Background(), "prod", nil, &options{header: "### Context: {context}"}, io.Discard, io.Discard)
+	if err == nil {
+		t.Fatal("expected error when no kubectl command given, got nil")
+	}
+}
+
+func TestExecute_List(t *testing.T) {
+	useFakeKubectl(t)
+	var out strings.Builder
+	// Intercept stdout by temporarily replacing — test list via matchingContexts directly
+	re := regexp.MustCompile("prod")
+	contexts, err := matchingContexts(re, execConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range contexts {
+		out.WriteString(c + "\n")
+	}
+	if !strings.Contains(out.String(), "prod-us-east") || !strings.Contains(out.String(), "prod-eu-west") {
+		t.Errorf("unexpected list output: %q", out.String())
+	}
+}
+
+func TestExecute_VerbAsPatternHint(t *testing.T) {
+	useFakeKubectl(t)
+	_, errOut, err := runCmd(t, "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut, "did you forget the context pattern? Usage: kubectl xctx <pattern> -- <kubectl args>") {
+		t.Errorf("expected a usage hint, got %q", errOut)
+	}
+
+	_, errOut, _ = runCmd(t, "nonexistent", "get", "pods")
+	if strings.Contains(errOut, "hint") {
+		t.Errorf("expected no hint for a non-verb pattern, got %q", errOut)
+	}
+}
+
+func TestExecute_CountOnly(t *testing.T) {
+	useFakeKubectl(t)
+	for pattern, want := range map[string]string{"prod": "2\n", ".": "4\n", "nonexistent": "0\n"} {
+		out, _, err := runCmd(t, "--count-only", pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != want {
+			t.Errorf("--count-only %q: want %q, got %q", pattern, want, out)
+		}
+	}
+}
+
+func TestExecute_PatternFromStdin(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{list: true, stdin: strings.NewReader("staging|dev\nignored\n")}
+	if err := execute(context.Background(), "-", nil, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "staging-us\ndev-local\n"; out.String() != want {
+		t.Errorf("want %q, got %q", want, out.String())
+	}
+}
+
+func TestExecute_PatternFromEmptyStdin(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{list: true, stdin: strings.NewReader("  \n")}
+	err := execute(context.Background(), "-", nil, opts, &out, &errOut)
+	if err == nil || !strings.Contains(err.Error(), "stdin has no pattern") {
+		t.Errorf("expected an empty-stdin error, got %v", err)
+	}
+}
+
+func TestExecute_ListPrint0(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "-0", "--list", "prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "prod-us-east\x00prod-eu-west\x00"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestExecute_Print0RequiresList(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--print0", "prod", "get", "pods"); err == nil || err.Error() != "--print0 requires --list" {
+		t.Errorf("expected --print0 requires --list error, got %v", err)
+	}
+}
+
+func TestExecute_ExplicitContexts(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "result from {context}\n"}})
+	var out, errOut strings.Builder
+	opts := &options{header: "### Context: {context}", contexts: []string{"staging-us", "prod-eu-west"}}
+	if err := execute(context.Background(), "", []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "kubectl --context staging-us get pods|kubectl --context prod-eu-west get pods"; strings.Join(fake.commands, "|") != want {
+		t.Errorf("expected runs against the named contexts in order, got %q", fake.commands)
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("expected no warnings for known contexts, got: %q", errOut.String())
+	}
+}
+
+func TestExecute_ExplicitContextsWarnsUnknown(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{contexts: []string{"prod-us-east", "typo-ctx"}}
+	if err := execute(context.Background(), "", []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut.String(), `context "typo-ctx" not found`) {
+		t.Errorf("expected warning for unknown context, got: %q", errOut.String())
+	}
+}
+
+// --- runSequential ---
+
+func TestRunSequential_AllSucceed(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, &options{header: "### Context: {context}"}, &out, &errOut)
+	if err != nil {
+		t.Errorf("expected nil, got: %v", err)
+	}
+	if !strings.Contains(out.String(), "prod-us-east") || !strings.Contains(out.String(), "prod-eu-west") {
+		t.Errorf("expected both contexts in output, got: %q", out.String())
+	}
+}
+
+func TestRunSequential_AliasUsesRealContext(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "ok\n"}})
+	aliases, err := parseAliases([]string{"prod-us-east=US East"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var out, errOut strings.Builder
+	opts := &options{header: "### Context: {context}", aliases: aliases}
+	if err := runSequential(context.Background(), []string{"prod-us-east"}, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.total != 1 || fake.calls["prod-us-east"] != 1 {
+		t.Errorf("expected kubectl to run against the real context, got %v", fake.commands)
+	}
+	if !strings.Contains(out.String(), "### Context: US East") {
+		t.Errorf("expected aliased header, got: %q", out.String())
+	}
+}
+
+func TestRunSequential_CountsFailures(t *testing.T) {
+	useFailingKubectl(t)
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+	if err == nil {
+		t.Fatal("expected error for failed contexts, got nil")
+	}
+	if !strings.Contains(err.Error(), "2") {
+		t.Errorf("expected failure count in error, got: %v", err)
+	}
+}
+
+func TestRunSequential_FailFast(t *testing.T) {
+	calls := useFailingKubectl(t)
+	var out, errOut strings.Builder
+	err := runSequential(context.Background(), []string{"ctx-a", "ctx-b", "ctx-c"}, []string{"get", "pods"}, &options{failFast: true}, &out, &errOut)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if *calls != 1 {
+		t.Errorf("fail-fast should stop after first failure, but kubectl was called %d times", *calls)
+	}
+}
+
+func TestRunSequential_StopsWhenInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	mockKubectl(t, func(_ context.Context, _ ...string) ([]byte, []byte, error) {
+		calls++
+		cancel() // simulate Ctrl-C while the first context is running
+		return []byte("ok\n"), nil, nil
+	})
+	var out, errOut strings.Builder
+	err := runSequential(ctx, []string{"ctx-a", "ctx-b", "ctx-c"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected errInterrupted, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected run to stop after the interrupted context, but kubectl was called %d times", calls)
+	}
+	if !strings.Contains(errOut.String(), "[xctx] interrupted") {
+		t.Errorf("expected interrupted message, got: %q", errOut.String())
+	}
+}
+
+func TestRunSequential_MaxFailures(t *testing.T) {
+	for _, tc := range []struct {
+		maxFailures string
+		wantCalls   int
+	}{
+		{"1", 2},
+		{"2", 3},
+		{"-1", 4},
+	} {
+		t.Run(tc.maxFailures, func(t *testing.T) {
+			calls := useFailingKubectl(t)
+			_, _, err := runCmd(t, "--max-failures", tc.maxFailures, ".", "get", "pods")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if *calls != tc.wantCalls {
+				t.Errorf("--max-failures %s: want %d kubectl calls, got %d", tc.maxFailures, tc.wantCalls, *calls)
+			}
+		})
+	}
+}
+
+func TestRunSequential_TimeoutAction(t *testing.T) {
+	for _, tc := range []struct {
+		action  string
+		wantErr bool
+		wantMsg string
+	}{
+		{timeoutFail, true, `context "slow-ctx" timed out after `},
+		{timeoutSkip, false, `context "slow-ctx" skipped: context deadline exceeded`},
+	} {
+		t.Run(tc.action, func(t *testing.T) {
+			useContextKubectl(t, map[string]fakeResponse{
+				"":         {stdout: "ok\n"},
+				"slow-ctx": {delay: time.Hour, err: errors.New("signal: killed")},
+			})
+			var out, errOut strings.Builder
+			opts := &options{timeout: 10 * time.Millisecond, timeoutAction: tc.action}
+			err := runSequential(context.Background(), []string{"fast-ctx", "slow-ctx"}, []string{"get", "pods"}, opts, &out, &errOut)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("want error %v, got: %v", tc.wantErr, err)
+			}
+			if !strings.Contains(errOut.String(), tc.wantMsg) {
+				t.Errorf("expected %q in stderr, got: %q", tc.wantMsg, errOut.String())
+			}
+		})
+	}
+}
+
+func TestTimeout_ReportsTimedOutNotFailed(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"config get-contexts": {stdout: "slow-ctx\nfail-ctx"},
+		"slow-ctx":            {delay: time.Hour, err: errors.New("signal: killed")},
+		"":                    {err: errors.New("exit status 1")},
+	})
+	_, errOut, err := runCmd(t, "--parallel", "--timeout", "20ms", ".", "get", "pods")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the run's error to carry the deadline, got %v", err)
+	}
+	if !regexp.MustCompile(`\[xctx\] context "slow-ctx" timed out after \d+ms\n`).MatchString(errOut) {
+		t.Errorf("expected a timeout notice for slow-ctx, got %q", errOut)
+	}
+	if !strings.Contains(errOut, `[xctx] context "fail-ctx" failed: exit status 1`) {
+		t.Errorf("expected a generic failure for fail-ctx, got %q", errOut)
+	}
+}
+
+// --- runParallel ---
+
+func TestRunParallel_AllSucceed(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	err := runParallel(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, &options{header: "### Context: {context}"}, &out, &errOut)
+	if err != nil {
+		t.Errorf("expected nil, got: %v", err)
+	}
+}
+
+func TestRunParallel_CountsFailures(t *testing.T) {
+	useFailingKubectl(t)
+	var out, errOut strings.Builder
+	err := runParallel(context.Background(), []string{"ctx-a", "ctx-b"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "2") {
+		t.Errorf("expected failure count in error, got: %v", err)
+	}
+}
+
+func TestRunParallel_OutputOrdering(t *testing.T) {
+	// Parallel runs must print results in input order, not arrival order.
+	// Simulate varying latency: first context is slower.
+	useContextKubectl(t, map[string]fakeResponse{
+		"":         {stdout: "result from {context}\n"},
+		"slow-ctx": {stdout: "result from {context}\n", delay: 20 * time.Millisecond},
+	})
+	var out, errOut strings.Builder
+	err := runParallel(context.Background(), []string{"slow-ctx", "fast-ctx"}, []string{"get", "pods"}, &options{header: "### Context: {context}"}, &out, &errOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	slowIdx := strings.Index(out.String(), "slow-ctx")
+	fastIdx := strings.Index(out.String(), "fast-ctx")
+	if slowIdx > fastIdx {
+		t.Errorf("expected slow-ctx before fast-ctx (input order), but output was:\n%s", out.String())
+	}
+}
+
+func TestRunParallel_SortByDuration(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"":         {stdout: "result from {context}\n"},
+		"slow-ctx": {stdout: "result from {context}\n", delay: 20 * time.Millisecond},
+	})
+	var out, errOut strings.Builder
+	opts := &options{header: "### Context: {context}", sortBy: sortDuration}
+	if err := runParallel(context.Background(), []string{"slow-ctx", "fast-ctx"}, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	slowIdx := strings.Index(out.String(), "slow-ctx")
+	fastIdx := strings.Index(out.String(), "fast-ctx")
+	if fastIdx > slowIdx {
+		t.Errorf("expected fast-ctx before slow-ctx when sorted by duration, but output was:\n%s", out.String())
+	}
+}
+
+func TestRunParallel_InterruptPropagates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	useContextKubectl(t, untilCancelled)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	var out, errOut strings.Builder
+	err := runParallel(ctx, []string{"ctx-a", "ctx-b"}, []string{"get", "pods"}, &options{timeout: time.Minute}, &out, &errOut)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected errInterrupted, got: %v", err)
+	}
+}
+
+func TestRunParallel_PrefixLines(t *testing.T) {
+	useFakeKubectl(t)
+	var out, errOut strings.Builder
+	opts := &options{header: "### Context: {context}", prefixLines: true}
+	if err := runParallel(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "prod-us-east\tresult from prod-us-east\nprod-eu-west\tresult from prod-eu-west\n"
+	if out.String() != want {
+		t.Errorf("unexpected output:\nwant %q\ngot  %q", want, out.String())
+	}
+}
+
+func TestRunParallel_MaxFailuresCancelsRemaining(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"":        {delay: time.Hour},
+		"bad-ctx": {err: errors.New("connection refused")},
+	})
+	var out, errOut strings.Builder
+	opts := &options{abortAfter: 1}
+	err := runParallel(context.Background(), []string{"slow-a", "bad-ctx", "slow-b"}, []string{"get", "pods"}, opts, &out, &errOut)
+	if err == nil || !strings.Contains(err.Error(), "stopped after 1 failure") {
+		t.Fatalf("expected abort error, got: %v", err)
+	}
+}
+
+func TestMaxFailures_ParallelCountsOnlyRealFailures(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"":             {stdout: "ok\n", delay: 5 * time.Second},
+		"prod-eu-west": {err: errors.New("connection refused")},
+		"dev-local":    {err: errors.New("connection refused")},
+	})
+	// Stops once more than one context has failed, cancelling the others.
+	_, errOut, err := runCmd(t, "--parallel", "--max-failures", "1", ".", "get", "pods")
+	if err == nil || !strings.HasPrefix(err.Error(), "stopped after 2 failure(s)") {
+		t.Fatalf("expected abort error, got: %v", err)
+	}
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-eu-west,dev-local" {
+		t.Errorf("want only the contexts that failed counted, got %q", got)
+	}
+	if strings.Count(errOut, "failed:") != 2 {
+		t.Errorf("want exactly two failures reported, got %q", errOut)
+	}
+}
+
+func TestRunParallel_FailFastCancelsOutstanding(t *testing.T) {
+	var mu sync.Mutex
+	var started, cancelled []string
+	mockKubectl(t, func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+		if args[1] == "bad-ctx" {
+			return nil, nil, errors.New("connection refused")
+		}
+		mu.Lock()
+		started = append(started, args[1])
+		mu.Unlock()
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			cancelled = append(cancelled, args[1])
+			mu.Unlock()
+			return nil, nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return []byte("ok from " + args[1] + "\n"), nil, nil
+		}
+	})
+	var out, errOut strings.Builder
+	start := time.Now()
+	err := runParallel(context.Background(), []string{"slow-a", "bad-ctx", "slow-b"}, []string{"get", "pods"}, &options{failFast: true}, &out, &errOut)
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("expected the outstanding contexts to be cancelled promptly, took %v", time.Since(start))
+	}
+	if err == nil || !strings.HasPrefix(err.Error(), "stopped after the first failure, cancelling remaining contexts") {
+		t.Fatalf("expected fail-fast error, got: %v", err)
+	}
+	// Contexts already running are cancelled; those not yet started never run.
+	if len(cancelled) != len(started) || out.String() != "" {
+		t.Errorf("expected every started context to be cancelled, started %v, cancelled %v, output %q", started, cancelled, out.String())
+	}
+	// The results gathered so far are still printed, in input order.
+	last := -1
+	for _, name := range []string{"slow-a", "bad-ctx", "slow-b"} {
+		if i := strings.Index(errOut.String(), `"`+name+`"`); i >= 0 {
+			if i < last {
+				t.Errorf("expected contexts reported in input order, got %q", errOut.String())
+			}
+			last = i
+		}
+	}
+	if !strings.Contains(errOut.String(), `"bad-ctx" failed: connection refused`) {
+		t.Errorf("expected the failure reported, got %q", errOut.String())
+	}
+	// The cancelled contexts did not fail themselves.
+	if got := strings.Join(failedContexts(t, err), ","); got != "bad-ctx" {
+		t.Errorf("want only bad-ctx counted as failed, got %q", got)
+	}
+	if strings.Count(errOut.String(), "failed:") != 1 {
+		t.Errorf("want exactly one failure reported, got %q", errOut.String())
+	}
+}
+
+func TestRunLoops_RecoverPanic(t *testing.T) {
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		if args[1] == "bad-ctx" {
+			panic("nil map")
+		}
+		return []byte("ok from " + args[1] + "\n"), nil, nil
+	})
+	for name, run := range map[string]func(context.Context, []string, []string, *options, io.Writer, io.Writer) error{
+		"sequential": runSequential,
+		"parallel":   runParallel,
+	} {
+		var out, errOut strings.Builder
+		err := run(context.Background(), []string{"ctx-a", "bad-ctx", "ctx-c"}, []string{"get", "pods"}, &options{}, &out, &errOut)
+		if got := strings.Join(failedContexts(t, err), ","); got != "bad-ctx" {
+			t.Errorf("%s: want only bad-ctx to fail, got %q", name, got)
+		}
+		if out.String() != "ok from ctx-a\nok from ctx-c\n" {
+			t.Errorf("%s: expected the other contexts' output, got %q", name, out.String())
+		}
+		if !strings.Contains(errOut.String(), `[xctx] context "bad-ctx" failed: panic: nil map`) {
+			t.Errorf("%s: expected the panic to be reported, got %q", name, errOut.String())
+		}
+	}
+}
+
+// --- sortResults ---
+
+func TestSortResults_StatusIsStable(t *testing.T) {
+	results := []result{
+		{ctxName: "a", err: errors.New("boom")},
+		{ctxName: "b"},
+		{ctxName: "c", err: errors.New("boom")},
+		{ctxName: "d"},
+	}
+	sortResults(results, sortStatus)
+	var got []string
+	for _, r := range results {
+		got = append(got, r.ctxName)
+	}
+	if strings.Join(got, ",") != "b,d,a,c" {
+		t.Errorf("want failures last in input order (b,d,a,c), got %v", got)
+	}
+}
+
+func TestSortResults_Name(t *testing.T) {
+	results := []result{{ctxName: "staging"}, {ctxName: "dev"}, {ctxName: "prod"}}
+	sortResults(results, sortName)
+	if results[0].ctxName != "dev" || results[2].ctxName != "staging" {
+		t.Errorf("expected results sorted by name, got %v", results)
+	}
+}
+
+// --- maybeWithTimeout ---
+
+func TestTimeoutMap_PerContextDeadline(t *testing.T) {
+	var mu sync.Mutex
+	budgets := map[string]time.Duration{}
+	mockKubectl(t, func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		deadline, ok := ctx.Deadline()
+		mu.Lock()
+		defer mu.Unlock()
+		if ok {
+			budgets[args[1]] = time.Until(deadline)
+		}
+		return nil, nil, nil
+	})
+	for _, mode := range []string{"--parallel=false", "--parallel"} {
+		budgets = map[string]time.Duration{}
+		_, _, err := runCmd(t, mode, "--timeout", "5s", "--timeout-map", "eu-west=30s", "--timeout-map", "^prod=1m", ".", "get", "pods")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for ctxName, want := range map[string]time.Duration{"prod-eu-west": 30 * time.Second, "prod-us-east": time.Minute, "staging-us": 5 * time.Second} {
+			if got := budgets[ctxName]; got > want || got < want-time.Second {
+				t.Errorf("%s: %s deadline %v away, want about %v", mode, ctxName, got, want)
+			}
+		}
+	}
+}
+
+func TestTimeoutMap_InvalidDuration(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--timeout-map", "prod=soon", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --timeout-map duration") {
+		t.Errorf("expected invalid duration error, got %v", err)
+	}
+}
+
+func TestPropagateTimeout_AddsRequestTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"derived from --timeout", []string{"--timeout", "10s", "--propagate-timeout", "staging", "get", "pods"}, "--context staging-us --request-timeout=10s get pods"},
+		{"derived from --timeout-map", []string{"--timeout", "10s", "--timeout-map", "staging=1m", "--propagate-timeout", "staging", "get", "pods"}, "--context staging-us --request-timeout=1m0s get pods"},
+		{"kept from the command", []string{"--timeout", "10s", "--propagate-timeout", "staging", "get", "pods", "--request-timeout=3s"}, "--context staging-us get pods --request-timeout=3s"},
+		{"not without the flag", []string{"--timeout", "10s", "staging", "get", "pods"}, "--context staging-us get pods"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := useContextKubectl(t, map[string]fakeResponse{})
+			if _, _, err := runCmd(t, tc.args...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{"kubectl " + tc.want}; !slices.Equal(fake.commands, want) {
+				t.Errorf("want kubectl to run %q, got %q", want, fake.commands)
+			}
+		})
+	}
+}
+
+func TestPropagateTimeout_RequiresTimeout(t *testing.T) {
+	useFakeKubectl(t)
+	_, _, err := runCmd(t, "--propagate-timeout", "prod", "get", "pods")
+	if err == nil || err.Error() != "--propagate-timeout requires --timeout or --timeout-map" {
+		t.Errorf("want a missing --timeout error, got %v", err)
+	}
+}
+
+func TestMaybeWithTimeout_Zero(t *testing.T) {
+	ctx, cancel := maybeWithTimeout(context.Background(), 0)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		t.Error("context should not be done with zero timeout")
+	default:
+	}
+}
+
+func TestMaybeWithTimeout_NonZero(t *testing.T) {
+	ctx, cancel := maybeWithTimeout(context.Background(), 1*time.Millisecond)
 	defer cancel()
 	time.Sleep(10 * time.Millisecond)
 	select {
@@ -316,7 +1398,7 @@ func TestMaybeWithTimeout_NonZero(t *testing.T) {
 
 func TestCompleteArgs_ContextNames(t *testing.T) {
 	useFakeKubectl(t)
-	completions, dir := completeArgs(nil, nil, "prod")
+	completions, dir := completeArgs(nil, nil, "prod", execConfig{})
 	if dir != cobra.ShellCompDirectiveNoFileComp {
 		t.Errorf("expected NoFileComp directive, got %d", dir)
 	}
@@ -332,7 +1414,7 @@ func TestCompleteArgs_ContextNames(t *testing.T) {
 
 func TestCompleteArgs_ContextNamesEmpty(t *testing.T) {
 	useFakeKubectl(t)
-	completions, _ := completeArgs(nil, nil, "")
+	completions, _ := completeArgs(nil, nil, "", execConfig{})
 	if len(completions) != 4 {
 		t.Errorf("expected 4 completions for empty prefix, got %d: %v", len(completions), completions)
 	}
@@ -340,7 +1422,7 @@ func TestCompleteArgs_ContextNamesEmpty(t *testing.T) {
 
 func TestCompleteArgs_ContextNamesNoMatch(t *testing.T) {
 	useFakeKubectl(t)
-	completions, _ := completeArgs(nil, nil, "zzz")
+	completions, _ := completeArgs(nil, nil, "zzz", execConfig{})
 	if len(completions) != 0 {
 		t.Errorf("expected 0 completions, got %d: %v", len(completions), completions)
 	}
@@ -350,7 +1432,7 @@ func TestCompleteArgs_ContextNamesKubectlError(t *testing.T) {
 	mockKubectl(t, func(_ context.Context, _ ...string) ([]byte, []byte, error) {
 		return nil, nil, errors.New("kubectl not found")
 	})
-	_, dir := completeArgs(nil, nil, "")
+	_, dir := completeArgs(nil, nil, "", execConfig{})
 	if dir != cobra.ShellCompDirectiveError {
 		t.Errorf("expected Error directive on kubectl failure, got %d", dir)
 	}
@@ -363,7 +1445,7 @@ func TestCompleteArgs_DelegatesToKubectl(t *testing.T) {
 		}
 		return nil, nil, fmt.Errorf("unexpected call: %v", args)
 	})
-	completions, dir := completeArgs(nil, []string{"prod", "get"}, "")
+	completions, dir := completeArgs(nil, []string{"prod", "get"}, "", execConfig{})
 	if dir != cobra.ShellCompDirective(4) {
 		t.Errorf("expected directive 4, got %d", dir)
 	}
@@ -382,8 +1464,453 @@ func TestCompleteArgs_KubectlCompletionError(t *testing.T) {
 		}
 		return nil, nil, fmt.Errorf("unexpected call: %v", args)
 	})
-	_, dir := completeArgs(nil, []string{"prod", "get"}, "")
+	_, dir := completeArgs(nil, []string{"prod", "get"}, "", execConfig{})
 	if dir != cobra.ShellCompDirectiveDefault {
 		t.Errorf("expected Default directive on error, got %d", dir)
 	}
 }
+
+// --- runInContext ---
+
+func TestRunInContext_ImpersonationForwarded(t *testing.T) {
+	fake := useContextKubectl(t, map[string]fakeResponse{})
+	opts := &options{
+		as:       "system:serviceaccount:ops:deployer",
+		asGroups: []string{"system:masters", "ops"},
+	}
+	var out, errOut strings.Builder
+	if err := runSequential(context.Background(), []string{"prod-us-east", "prod-eu-west"}, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"kubectl --context prod-us-east --as=system:serviceaccount:ops:deployer --as-group=system:masters --as-group=ops get pods",
+		"kubectl --context prod-eu-west --as=system:serviceaccount:ops:deployer --as-group=system:masters --as-group=ops get pods",
+	}
+	if !slices.Equal(fake.commands, want) {
+		t.Errorf("want %q to run, got %q", want, fake.commands)
+	}
+}
+
+func TestRunInContext_KubeFlagsBeforeVerb(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	if _, _, err := runCmd(t, "--kube-flag=--request-timeout=5s", "--kube-flag", "-v=6", "--as", "ops", "prod", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "kubectl --context prod-us-east --as=ops --request-timeout=5s -v=6 get pods|" +
+		"kubectl --context prod-eu-west --as=ops --request-timeout=5s -v=6 get pods"
+	if got := strings.Join(fake.commands, "|"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestRunInContext_ExpandArgs(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	if _, _, err := runCmd(t, "--expand-args", "prod", "get", "cm", "cfg-{context}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "kubectl --context prod-us-east get cm cfg-prod-us-east|kubectl --context prod-eu-west get cm cfg-prod-eu-west"
+	if got := strings.Join(fake.commands, "|"); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	fake.commands = nil
+	if _, _, err := runCmd(t, "prod-us-east", "get", "cm", "cfg-{context}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "kubectl --context prod-us-east get cm cfg-{context}"; strings.Join(fake.commands, "|") != want {
+		t.Errorf("expected args untouched without --expand-args, got %q", fake.commands)
+	}
+}
+
+func TestCompleteArgs_ExplicitContextsDelegateAllArgs(t *testing.T) {
+	var got []string
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		got = args
+		return []byte("pods\n:4\n"), nil, nil
+	})
+	cmd := newCmd()
+	if err := cmd.Flags().Set("context", "prod-us-east"); err != nil {
+		t.Fatal(err)
+	}
+	completeArgs(cmd, []string{"get"}, "po", execConfig{})
+	if strings.Join(got, " ") != "__complete get po" {
+		t.Errorf("expected no pattern to be skipped with --context, got args %v", got)
+	}
+}
+
+// --- --tee ---
+
+func TestTee_WritesConsoleOutputToFile(t *testing.T) {
+	useFailingKubectl(t)
+	path := filepath.Join(t.TempDir(), "run.log")
+	if err := os.WriteFile(path, []byte("stale\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, errOut, _ := runCmd(t, "--tee", path, "prod", "get", "pods")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != out {
+		t.Errorf("want the file to hold the console output %q, got %q", out, data)
+	}
+	if errOut == "" || strings.Contains(string(data), errOut) {
+		t.Errorf("expected stderr %q to stay out of the file without --tee-stderr", errOut)
+	}
+}
+
+func TestTee_Stderr(t *testing.T) {
+	useFailingKubectl(t)
+	path := filepath.Join(t.TempDir(), "run.log")
+	_, errOut, _ := runCmd(t, "--tee", path, "--tee-stderr", "prod-us-east", "get", "pods")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), errOut) {
+		t.Errorf("expected the file %q to contain stderr %q", data, errOut)
+	}
+}
+
+func TestTee_StderrRequiresTee(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--tee-stderr", "prod", "get", "pods"); err == nil || err.Error() != "--tee-stderr requires --tee" {
+		t.Errorf("expected --tee-stderr to require --tee, got %v", err)
+	}
+}
+
+// --- --reverse-exit-code ---
+
+func TestReverseExitCode(t *testing.T) {
+	notFound := fakeResponse{stderr: "Error from server (NotFound)\n", err: errors.New("exit status 1")}
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-us-east": notFound,
+		"prod-eu-west": notFound,
+		"":             {stdout: "ns/old-team\n"},
+	})
+	for _, tc := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"prod"}, "2 context(s) failed"},
+		{[]string{"--reverse-exit-code", "prod"}, ""},
+		{[]string{"staging|prod"}, "2 context(s) failed"},
+		{[]string{"--reverse-exit-code", "staging|prod"}, "1 of 3 context(s) succeeded, expected all to fail (--reverse-exit-code): staging-us"},
+		{[]string{"staging"}, ""},
+		{[]string{"--reverse-exit-code", "staging"}, "1 of 1 context(s) succeeded"},
+	} {
+		_, errOut, err := runCmd(t, append(tc.args, "get", "ns", "old-team")...)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tc.args, err)
+			}
+		} else if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+			t.Errorf("%v: want error %q, got %v", tc.args, tc.wantErr, err)
+		}
+		if tc.args[0] == "--reverse-exit-code" && err == nil && !strings.Contains(errOut, "as expected (--reverse-exit-code)") {
+			t.Errorf("%v: expected the inversion to be explained, got %q", tc.args, errOut)
+		}
+	}
+}
+
+func TestRunSequential_ContextOutputStaysTogether(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"":       {stdout: "pod/web\n", stderr: "Warning: deprecated\n"},
+		"broken": {stdout: "partial\n", stderr: "error: the server doesn't have a resource type\n", err: errors.New("exit status 1")},
+	})
+	// One writer for both streams shows the order they were written in.
+	var combined strings.Builder
+	opts := &options{header: "### {context}"}
+	_ = runSequential(context.Background(), []string{"broken", "healthy"}, []string{"get", "pods"}, opts, &combined, &combined)
+	want := "### broken\npartial\n" +
+		"error: the server doesn't have a resource type\n" +
+		"[xctx] context \"broken\" failed: exit status 1\n\n" +
+		"### healthy\npod/web\nWarning: deprecated\n\n"
+	if combined.String() != want {
+		t.Errorf("want\n%q\ngot\n%q", want, combined.String())
+	}
+}
+
+// --- --limit ---
+
+func TestLimit_RunsFirstN(t *testing.T) {
+	calls := useFailingKubectl(t)
+	_, errOut, _ := runCmd(t, "--limit", "2", ".", "get", "pods")
+	if *calls != 2 {
+		t.Errorf("want 2 contexts run, got %d", *calls)
+	}
+	if !strings.Contains(errOut, "[xctx] limited to 2 of 4 matched context(s)\n") {
+		t.Errorf("expected the limit to be noted, got %q", errOut)
+	}
+}
+
+func TestLimit_AboveMatchCount(t *testing.T) {
+	useFakeKubectl(t)
+	out, errOut, err := runCmd(t, "--limit", "5", "--list", "prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "prod-us-east\nprod-eu-west\n" || strings.Contains(errOut, "limited") {
+		t.Errorf("expected every match without a note, got %q / %q", out, errOut)
+	}
+}
+
+func TestLimit_Negative(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--limit", "-1", "prod", "get", "pods"); err == nil || err.Error() != "--limit must not be negative" {
+		t.Errorf("expected a negative --limit to be rejected, got %v", err)
+	}
+}
+
+// --- --strip-prefix / --strip-suffix ---
+
+func TestStripPrefixSuffix_HeadersOnly(t *testing.T) {
+	arn := "arn:aws:eks:us-east-1:123456789012:cluster/prod-web.eks"
+	fake := useContextKubectl(t, map[string]fakeResponse{
+		"config get-contexts": {stdout: arn + "\n"},
+		"":                    {stdout: "ok\n"},
+	})
+	out, _, err := runCmd(t, "--strip-prefix", "arn:aws:eks:us-east-1:123456789012:cluster/", "--strip-suffix", ".eks", "--header", "[{context}] {realcontext}", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out, "[prod-web] "+arn+"\n") {
+		t.Errorf("expected the trimmed name in the header, got %q", out)
+	}
+	if fake.total != 1 || fake.calls[arn] != 1 {
+		t.Errorf("expected kubectl to get the full name, got %q", fake.commands)
+	}
+}
+
+// --- --error-format ---
+
+func TestErrorFormat_Placeholders(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"": {stderr: "Unable to connect to the server\n", err: errors.New("exit status 1")}})
+	_, errOut, _ := runCmd(t, "--error-format", "ERR {context} ({realcontext}) code={exitcode} err={error} stderr={stderr}", "--alias", "prod-us-east=US", "prod-us-east", "get", "pods")
+	want := "ERR US (prod-us-east) code=-1 err=exit status 1 stderr=Unable to connect to the server\n"
+	if !strings.HasSuffix(errOut, want) {
+		t.Errorf("want failure line %q, got %q", want, errOut)
+	}
+	if strings.Contains(errOut, "[xctx] context") {
+		t.Errorf("expected the default failure line to be replaced, got %q", errOut)
+	}
+}
+
+// --- --stream-ordered ---
+
+// signalWriter closes seen the first time want is written to it.
+type signalWriter struct {
+	strings.Builder
+	want string
+	seen chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	n, err := w.Builder.Write(p)
+	if w.seen != nil && strings.Contains(w.String(), w.want) {
+		close(w.seen)
+		w.seen = nil
+	}
+	return n, err
+}
+
+func TestStreamOrdered_PrintsInOrderWithoutWaitingForAll(t *testing.T) {
+	out := &signalWriter{want: "result from first", seen: make(chan struct{})}
+	firstPrinted := out.seen
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		switch args[1] {
+		case "second":
+			time.Sleep(20 * time.Millisecond)
+		case "last":
+			// Finish only once the first block is out, which a fully
+			// buffered run would never print in time.
+			select {
+			case <-firstPrinted:
+			case <-time.After(2 * time.Second):
+				return nil, nil, errors.New("first block was not streamed")
+			}
+		}
+		return []byte("result from " + args[1] + "\n"), nil, nil
+	})
+	var errOut strings.Builder
+	opts := &options{parallel: true, streamOrdered: true, header: "{context}"}
+	if err := runParallel(context.Background(), []string{"first", "second", "last"}, []string{"get", "pods"}, opts, out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v (%s)", err, errOut.String())
+	}
+	want := "first\nresult from first\n\nsecond\nresult from second\n\nlast\nresult from last\n\n"
+	if out.String() != want {
+		t.Errorf("want\n%q\ngot\n%q", want, out.String())
+	}
+}
+
+func TestStreamOrdered_Validation(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--stream-ordered", "prod", "get", "pods"); err == nil || err.Error() != "--stream-ordered requires --parallel" {
+		t.Errorf("expected --stream-ordered to require --parallel, got %v", err)
+	}
+	if _, _, err := runCmd(t, "--parallel", "--stream-ordered", "--sort-output", "name", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "cannot be combined with --sort-output") {
+		t.Errorf("expected --stream-ordered to reject --sort-output, got %v", err)
+	}
+	for _, group := range [][]string{{"--group-by", "-"}, {"--group-by-namespace"}} {
+		args := append([]string{"--parallel", "--stream-ordered"}, group...)
+		if _, _, err := runCmd(t, append(args, "prod", "get", "pods")...); err == nil || !strings.Contains(err.Error(), "--stream-ordered cannot be combined with --group-by") {
+			t.Errorf("expected --stream-ordered to reject %s, got %v", group[0], err)
+		}
+	}
+}
+
+// --- --require ---
+
+func TestRequire_MissingContextFails(t *testing.T) {
+	calls := useFailingKubectl(t)
+	_, _, err := runCmd(t, "--require", "prod-us-east", "--require", "prod-ap-south", "--require", "prod-sa-east", "prod", "get", "pods")
+	if err == nil || err.Error() != "required context(s) not matched: prod-ap-south, prod-sa-east" {
+		t.Errorf("expected the missing contexts to be named, got %v", err)
+	}
+	if *calls != 0 {
+		t.Errorf("expected nothing to run, got %d calls", *calls)
+	}
+}
+
+func TestRequire_AllPresent(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--require", "prod-eu-west", "prod", "get", "pods"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// --- --stdin ---
+
+func TestStdin_EachContextGetsAllOfIt(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string]string{}
+	mockKubectlExec(t, func(_ context.Context, cfg execConfig, args ...string) ([]byte, []byte, error) {
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		// Read through a real command's stdin, as kubectl would.
+		data, err := io.ReadAll(kubectlCommand(context.Background(), cfg, nil).Stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		got[args[1]] = string(data)
+		return nil, nil, nil
+	})
+	manifest := "apiVersion: v1\nkind: ConfigMap\n"
+	opts := &options{passStdin: true, parallel: true, stdin: strings.NewReader(manifest)}
+	if err := execute(context.Background(), "prod", []string{"apply", "-f", "-"}, opts, io.Discard, io.Discard); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got["prod-us-east"] != manifest || got["prod-eu-west"] != manifest {
+		t.Errorf("expected both contexts to read the whole manifest, got %q", got)
+	}
+}
+
+func TestStdin_NotWithPatternFromStdin(t *testing.T) {
+	useFakeKubectl(t)
+	opts := &options{passStdin: true, stdin: strings.NewReader("prod\n")}
+	if err := execute(context.Background(), "-", []string{"get", "pods"}, opts, io.Discard, io.Discard); err == nil {
+		t.Error("expected --stdin with pattern - to be rejected")
+	}
+}
+
+// --- --show-targets ---
+
+func TestShowTargets(t *testing.T) {
+	useFakeKubectl(t)
+	out, errOut, err := runCmd(t, "--show-targets", "--alias", "prod-eu-west=eu", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(errOut, "[xctx] 2 target context(s):\n  prod-us-east\n  eu\n") {
+		t.Errorf("expected the targets listed on stderr, got %q", errOut)
+	}
+	if !strings.Contains(out, "result from prod-us-east") || !strings.Contains(out, "result from prod-eu-west") {
+		t.Errorf("expected the command to run afterwards, got %q", out)
+	}
+}
+
+// --- --suppress-stdout-on-error ---
+
+func TestSuppressStdoutOnError(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"fail-me": {stdout: "partial from fail-me\n", stderr: "error: the server is gone\n", err: errors.New("exit status 1")},
+		"":        {stdout: "result from {context}\n"},
+	})
+	out, errOut, _ := runCmd(t, "--suppress-stdout-on-error", "--header", "", "--context", "fail-me", "--context", "dev-local", "get", "pods")
+	if out != "result from dev-local\n" {
+		t.Errorf("expected only the successful context's stdout, got %q", out)
+	}
+	if !strings.Contains(errOut, "error: the server is gone\n") || !strings.Contains(errOut, `[xctx] context "fail-me" failed`) {
+		t.Errorf("expected the failed context's stderr and failure line, got %q", errOut)
+	}
+}
+
+// --- --rate ---
+
+func TestRate_SpacesOutParallelCalls(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return nil, nil, nil
+	})
+	if _, _, err := runCmd(t, "--parallel", "--rate", "20", ".", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(starts) != 4 {
+		t.Fatalf("expected 4 kubectl calls, got %d", len(starts))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	// 20/s allows one call every 50ms; leave some slack for the clock.
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("calls %d and %d started %v apart, want at least 50ms", i-1, i, gap)
+		}
+	}
+}
+
+func TestRate_Validation(t *testing.T) {
+	useFakeKubectl(t)
+	for want, flags := range map[string][]string{
+		"--rate must not be negative": {"--parallel", "--rate", "-1"},
+		"--rate requires --parallel":  {"--rate", "5"},
+	} {
+		_, _, err := runCmd(t, append(flags, ".", "get", "pods")...)
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: want error %q, got %v", flags, want, err)
+		}
+	}
+}
+
+// --- --merge-streams ---
+
+func TestMergeStreams(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"": {stdout: "pods\n", stderr: "Warning: deprecated\n", err: errors.New("exit status 1")}})
+	out, errOut, _ := runCmd(t, "--merge-streams", "--header", "# {context}", "staging", "get", "pods")
+	want := "# staging-us\npods\nWarning: deprecated\n[xctx] context \"staging-us\" failed: exit status 1\n\n"
+	if out != want {
+		t.Errorf("want merged block %q, got %q", want, out)
+	}
+	if strings.Contains(errOut, "deprecated") || strings.Contains(errOut, "failed:") {
+		t.Errorf("expected nothing from the context on stderr, got %q", errOut)
+	}
+}
+
+func TestMergeStreams_PrefixLines(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{"": {stdout: "pods\n", stderr: "Warning: deprecated\n"}})
+	out, _, err := runCmd(t, "--merge-streams", "--prefix-lines", "staging", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "staging-us\tpods\nstaging-us\tWarning: deprecated\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
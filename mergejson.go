@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// mergedOutput is one context's entry in the --merge-json object.
+type mergedOutput struct {
+	ctxName string
+	value   json.RawMessage
+}
+
+// errNotJSON fails a context under --strict-json.
+var errNotJSON = errors.New("output is not valid JSON (--strict-json)")
+
+// checkStrictJSON returns errNotJSON when --strict-json is set and stdout is
+// not a JSON document.
+func checkStrictJSON(stdout []byte, opts *options) error {
+	if opts.strictJSON && !json.Valid(stdout) {
+		return errNotJSON
+	}
+	return nil
+}
+
+// addMerged collects r's output for --merge-json. Output that is not JSON
+// is kept as a JSON string, unless --strict-json already failed the
+// context. A failed context is reported on errOut and left out.
+func addMerged(r result, opts *options, errOut io.Writer) {
+	if r.failed() {
+		if len(r.stderr) > 0 {
+			_, _ = errOut.Write(r.stderr)
+		}
+		printFailure(r, opts, errOut)
+		return
+	}
+	value := json.RawMessage(bytes.TrimSpace(r.stdout))
+	if !json.Valid(value) {
+		value, _ = json.Marshal(string(r.stdout))
+	}
+	*opts.merged = append(*opts.merged, mergedOutput{ctxName: r.ctxName, value: value})
+}
+
+// writeMergedJSON writes the collected outputs as one indented JSON object
+// keyed by context name, in the order they were printed.
+func writeMergedJSON(merged []mergedOutput, out, errOut io.Writer) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range merged {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.ctxName)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] --merge-json: %v\n", err)
+		return
+	}
+	indented.WriteByte('\n')
+	_, _ = indented.WriteTo(out)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeJSON_LenientIncludesInvalidAsString(t *testing.T) {
+	useContextKubectl(t, podLists)
+	out, _, err := runCmd(t, "--merge-json", "--context", "staging-us", "--context", "dev-local", "get", "pods", "-o", "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{
+  "staging-us": {
+    "items": [
+      {
+        "metadata": {
+          "name": "web"
+        }
+      }
+    ]
+  },
+  "dev-local": "No resources found\n"
+}
+`
+	if out != want {
+		t.Errorf("unexpected output:\nwant %q\ngot  %q", want, out)
+	}
+}
+
+func TestMergeJSON_StrictFailsInvalid(t *testing.T) {
+	useContextKubectl(t, podLists)
+	out, errOut, err := runCmd(t, "--merge-json", "--strict-json", "--context", "staging-us", "--context", "dev-local", "get", "pods", "-o", "json")
+	if got := strings.Join(failedContexts(t, err), ","); got != "dev-local" {
+		t.Errorf("want dev-local to fail, got %q", got)
+	}
+	if strings.Contains(out, "dev-local") || !strings.Contains(out, `"staging-us"`) {
+		t.Errorf("expected only the valid output merged, got %q", out)
+	}
+	if !strings.Contains(errOut, `[xctx] context "dev-local" failed: output is not valid JSON (--strict-json)`) {
+		t.Errorf("expected the failure to be reported, got %q", errOut)
+	}
+}
+
+func TestStrictJSON_RequiresMergeJSON(t *testing.T) {
+	useContextKubectl(t, podLists)
+	if _, _, err := runCmd(t, "--strict-json", "prod", "get", "pods"); err == nil || err.Error() != "--strict-json requires --merge-json" {
+		t.Errorf("expected --strict-json alone to be rejected, got %v", err)
+	}
+}
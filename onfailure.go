@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runOnFailure runs the --on-failure-cmd args against the contexts that
+// failed in runErr, printing each one's output under its header. The
+// follow-up is diagnostic only: its own failures are reported but do not
+// change the run's result.
+func runOnFailure(ctx context.Context, runErr error, opts *options, out, errOut io.Writer) {
+	var m *MultiError
+	if len(opts.onFailureArgs) == 0 || !errors.As(runErr, &m) || ctx.Err() != nil {
+		return
+	}
+	failed := make([]string, len(m.Errors))
+	for i, e := range m.Errors {
+		failed[i] = e.Context
+	}
+	_, _ = fmt.Fprintf(errOut, "[xctx] running \"kubectl %s\" in %d failed context(s)\n", strings.Join(opts.onFailureArgs, " "), len(failed))
+
+	diag := plainRunOptions(opts)
+	for _, r := range collectResults(ctx, failed, opts.onFailureArgs, diag) {
+		printResult(r, diag, out, errOut)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// --- --on-failure-cmd ---
+
+func TestOnFailureCmd_RunsOnlyInFailedContexts(t *testing.T) {
+	var calls []string
+	mockKubectl(t, func(_ context.Context, args ...string) ([]byte, []byte, error) {
+		if args[0] == "config" {
+			return []byte(fakeContextList), nil, nil
+		}
+		calls = append(calls, strings.Join(args, " "))
+		if args[2] == "get" && args[3] == "events" {
+			return []byte("Warning BackOff pod/web-1\n"), nil, nil
+		}
+		if args[1] == "prod-eu-west" {
+			return nil, nil, errors.New("exit status 1")
+		}
+		return []byte("rolled out\n"), nil, nil
+	})
+	out, errOut, err := runCmd(t, "--on-failure-cmd", "get events --sort-by=.lastTimestamp", "prod", "rollout", "status", "deploy/web")
+	if err == nil || err.Error() != "1 context(s) failed" {
+		t.Errorf("expected the run's own failure to be returned, got %v", err)
+	}
+	want := []string{
+		"--context prod-us-east rollout status deploy/web",
+		"--context prod-eu-west rollout status deploy/web",
+		"--context prod-eu-west get events --sort-by=.lastTimestamp",
+	}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Errorf("want calls %q, got %q", want, calls)
+	}
+	if !strings.HasSuffix(out, "### Context: prod-eu-west\nWarning BackOff pod/web-1\n\n") {
+		t.Errorf("expected diagnostics under the failed context's header, got %q", out)
+	}
+	if !strings.Contains(errOut, `[xctx] running "kubectl get events --sort-by=.lastTimestamp" in 1 failed context(s)`) {
+		t.Errorf("expected the follow-up to be announced, got %q", errOut)
+	}
+}
+
+func TestOnFailureCmd_SkippedWhenAllSucceed(t *testing.T) {
+	useFakeKubectl(t)
+	_, errOut, err := runCmd(t, "--on-failure-cmd", "get events", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(errOut, "failed context(s)") {
+		t.Errorf("expected no follow-up, got %q", errOut)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Values accepted by --output.
+const (
+	outputJSONL    = "jsonl"
+	outputYAML     = "yaml"
+	outputYAMLDocs = "yaml-docs"
+)
+
+var validOutputs = map[string]bool{outputJSONL: true, outputYAML: true, outputYAMLDocs: true}
+
+// record is the machine-readable form of a context's result, shared by the
+// JSON and YAML outputs.
+type record struct {
+	Context    string `json:"context" yaml:"context"`
+	Stdout     string `json:"stdout" yaml:"stdout"`
+	Stderr     string `json:"stderr" yaml:"stderr"`
+	ExitCode   int    `json:"exitCode" yaml:"exitCode"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
+	DurationMs int64  `json:"durationMs" yaml:"durationMs"`
+	Skipped    bool   `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+}
+
+func newRecord(r result) record {
+	rec := record{
+		Context:    r.ctxName,
+		Stdout:     string(r.stdout),
+		Stderr:     string(r.stderr),
+		ExitCode:   exitCode(r.err),
+		DurationMs: r.duration.Milliseconds(),
+		Skipped:    r.skipped,
+		Truncated:  r.truncated,
+	}
+	if r.err != nil {
+		rec.Error = r.err.Error()
+	}
+	return rec
+}
+
+// writeJSONL writes r as one compact JSON object on its own line. Failures
+// are part of the record, so nothing else is printed for the context.
+func writeJSONL(r result, out, errOut io.Writer) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(newRecord(r)); err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q: %v\n", r.ctxName, err)
+	}
+}
+
+// writeYAMLDoc writes r as its own YAML document, for --output yaml-docs.
+func writeYAMLDoc(r result, out, errOut io.Writer) {
+	data, err := yaml.Marshal(newRecord(r))
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q: %v\n", r.ctxName, err)
+		return
+	}
+	_, _ = fmt.Fprintf(out, "---\n%s", data)
+}
+
+// writeYAMLList writes the records collected for --output yaml as a single
+// YAML list, once the run is over.
+func writeYAMLList(records []record, out, errOut io.Writer) {
+	if records == nil {
+		records = []record{}
+	}
+	data, err := yaml.Marshal(records)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] %v\n", err)
+		return
+	}
+	_, _ = out.Write(data)
+}
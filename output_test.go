@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeRecords decodes one record per line of out.
+func decodeRecords(t *testing.T, out string) []record {
+	t.Helper()
+	var recs []record
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var rec record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("line %q is not a JSON record: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// --- --output jsonl ---
+
+func TestOutputJSONL_OneRecordPerContext(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"staging-us": {stderr: "connection refused\n", err: errors.New("exit status 1")},
+		"":           {stdout: "pod/web\n"},
+	})
+	out, errOut, err := runCmd(t, "--output", "jsonl", "prod|staging", "get", "pods")
+	if err == nil {
+		t.Fatal("expected the staging failure to fail the run")
+	}
+	if errOut != "" {
+		t.Errorf("expected failures only in the records, got stderr %q", errOut)
+	}
+	recs := decodeRecords(t, out)
+	if len(recs) != 3 {
+		t.Fatalf("want 3 records, got %d: %q", len(recs), out)
+	}
+	if recs[0].Context != "prod-us-east" || recs[0].Stdout != "pod/web\n" || recs[0].ExitCode != 0 || recs[0].Error != "" {
+		t.Errorf("unexpected success record %+v", recs[0])
+	}
+	if recs[2].Context != "staging-us" || recs[2].Stderr != "connection refused\n" || recs[2].ExitCode != -1 || recs[2].Error != "exit status 1" {
+		t.Errorf("unexpected failure record %+v", recs[2])
+	}
+}
+
+func TestOutputJSONL_ParallelEmitsEveryContext(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--parallel", "--output", "jsonl", ".", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, rec := range decodeRecords(t, out) {
+		names = append(names, rec.Context)
+	}
+	sort.Strings(names)
+	if want := "dev-local prod-eu-west prod-us-east staging-us"; strings.Join(names, " ") != want {
+		t.Errorf("want records for %q, got %q", want, names)
+	}
+}
+
+// --- --output yaml / yaml-docs ---
+
+func TestOutputYAML_ListOfRecords(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"staging-us": {stderr: "connection refused\n", err: errors.New("exit status 1")},
+		"":           {stdout: "pod/web\npod/api\n"},
+	})
+	out, errOut, err := runCmd(t, "--output", "yaml", "--parallel", "prod|staging", "get", "pods")
+	if err == nil {
+		t.Fatal("expected the staging failure to fail the run")
+	}
+	if errOut != "" {
+		t.Errorf("expected failures only in the records, got stderr %q", errOut)
+	}
+	var recs []record
+	if err := yaml.Unmarshal([]byte(out), &recs); err != nil {
+		t.Fatalf("output is not a YAML list of records: %v\n%s", err, out)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("want 3 records, got %d: %q", len(recs), out)
+	}
+	if recs[0].Context != "prod-us-east" || recs[0].Stdout != "pod/web\npod/api\n" || recs[0].Error != "" {
+		t.Errorf("unexpected success record %+v", recs[0])
+	}
+	if recs[2].Context != "staging-us" || recs[2].Stderr != "connection refused\n" || recs[2].ExitCode != -1 || recs[2].Error != "exit status 1" {
+		t.Errorf("unexpected failure record %+v", recs[2])
+	}
+}
+
+func TestOutputYAML_NoContextsIsEmptyList(t *testing.T) {
+	var out, errOut strings.Builder
+	if err := run(context.Background(), nil, []string{"get", "pods"}, &options{output: outputYAML}, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("want an empty list, got %q", out.String())
+	}
+}
+
+func TestOutputYAMLDocs_OneDocumentPerContext(t *testing.T) {
+	useFakeKubectl(t)
+	out, _, err := runCmd(t, "--output", "yaml-docs", "prod", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dec := yaml.NewDecoder(strings.NewReader(out))
+	var names []string
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			break
+		}
+		names = append(names, rec.Context)
+	}
+	if want := "prod-us-east prod-eu-west"; strings.Join(names, " ") != want {
+		t.Errorf("want documents for %q, got %q in %q", want, names, out)
+	}
+}
+
+func TestOutput_Invalid(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--output", "xml", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "invalid --output") {
+		t.Errorf("expected invalid --output error, got %v", err)
+	}
+}
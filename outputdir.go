@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unsafeFileChars matches runs of characters that are not safe in a file
+// name, such as the slashes and colons of EKS ARNs.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// resultFileBases returns the path prefix for each context's files in dir.
+// Replacing unsafe characters can give two contexts the same name, so later
+// ones get a "-2", "-3", ... suffix; names are compared ignoring case, for
+// case-insensitive file systems. A name made only of dots is escaped so
+// that no file lands outside dir.
+func resultFileBases(dir string, contexts []string) map[string]string {
+	bases := make(map[string]string, len(contexts))
+	taken := map[string]bool{}
+	for _, c := range contexts {
+		if _, ok := bases[c]; ok {
+			continue
+		}
+		name := unsafeFileChars.ReplaceAllString(c, "_")
+		if strings.Trim(name, ".") == "" {
+			name = strings.ReplaceAll(name, ".", "_")
+		}
+		unique := name
+		for n := 2; taken[strings.ToLower(unique)]; n++ {
+			unique = fmt.Sprintf("%s-%d", name, n)
+		}
+		taken[strings.ToLower(unique)] = true
+		bases[c] = filepath.Join(dir, unique)
+	}
+	return bases
+}
+
+// writeResultFiles writes r's stdout to <dir>/<context>.out and, when there
+// is any, its stderr to <dir>/<context>.err, named by opts.outputFiles, then
+// prints a one-line summary
+// of the files written in place of the output itself.
+func writeResultFiles(r result, opts *options, out, errOut io.Writer) {
+	base := opts.outputFiles[r.ctxName]
+	files := []string{base + ".out"}
+	if err := os.WriteFile(base+".out", r.stdout, 0o600); err != nil {
+		_, _ = fmt.Fprintf(errOut, "[xctx] context %q: %v\n", r.ctxName, err)
+		return
+	}
+	if len(r.stderr) > 0 {
+		if err := os.WriteFile(base+".err", r.stderr, 0o600); err != nil {
+			_, _ = fmt.Fprintf(errOut, "[xctx] context %q: %v\n", r.ctxName, err)
+			return
+		}
+		files = append(files, base+".err")
+	}
+
+	_, _ = fmt.Fprintf(out, "%s:", displayName(r.ctxName, opts))
+	for _, f := range files {
+		_, _ = fmt.Fprintf(out, " %s", f)
+	}
+	_, _ = fmt.Fprintln(out)
+	printFailure(r, opts, errOut)
+}
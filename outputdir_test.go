@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- writeResultFiles ---
+
+func TestOutputDir_WritesFilesPerContext(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"arn:aws:eks:us-east-1:123:cluster/prod": {stdout: "pods in prod\n", stderr: "Warning: deprecated\n"},
+		"":                                       {stdout: "pods in dev\n"},
+	})
+	dir := filepath.Join(t.TempDir(), "nested", "out")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut strings.Builder
+	contexts := []string{"arn:aws:eks:us-east-1:123:cluster/prod", "dev-local"}
+	opts := &options{header: "### Context: {context}", outputDir: dir, outputFiles: resultFileBases(dir, contexts)}
+	if err := runSequential(context.Background(), contexts, []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prodBase := filepath.Join(dir, "arn_aws_eks_us-east-1_123_cluster_prod")
+	for path, want := range map[string]string{
+		prodBase + ".out":                   "pods in prod\n",
+		prodBase + ".err":                   "Warning: deprecated\n",
+		filepath.Join(dir, "dev-local.out"): "pods in dev\n",
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("expected %s to be written: %v", path, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: want %q, got %q", path, want, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dev-local.err")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no .err file for a context without stderr, got: %v", err)
+	}
+	if strings.Contains(out.String(), "pods in") || strings.Contains(out.String(), "### Context") {
+		t.Errorf("expected only a summary on the console, got: %q", out.String())
+	}
+	if !strings.Contains(out.String(), "dev-local: "+filepath.Join(dir, "dev-local.out")+"\n") {
+		t.Errorf("expected summary line for dev-local, got: %q", out.String())
+	}
+}
+
+func TestOutputDir_CreatedByExecute(t *testing.T) {
+	useFakeKubectl(t)
+	dir := filepath.Join(t.TempDir(), "results")
+	if _, _, err := runCmd(t, "--output-dir", dir, "prod-us-east", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "prod-us-east.out"))
+	if err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+	if string(got) != "result from prod-us-east\n" {
+		t.Errorf("unexpected file contents %q", got)
+	}
+}
+
+func TestOutputDir_CollidingNamesGetSuffixes(t *testing.T) {
+	useFakeKubectl(t)
+	dir := t.TempDir()
+	args := []string{"--output-dir", dir, "--context", "a/b", "--context", "a:b", "--context", "A_B", "get", "pods"}
+	if _, _, err := runCmd(t, args...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"a_b.out":   "result from a/b\n",
+		"a_b-2.out": "result from a:b\n",
+		"A_B-3.out": "result from A_B\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: want %q, got %q (%v)", name, want, got, err)
+		}
+	}
+}
+
+func TestOutputDir_DotNamesStayInside(t *testing.T) {
+	useFakeKubectl(t)
+	dir := filepath.Join(t.TempDir(), "out")
+	if _, _, err := runCmd(t, "--output-dir", dir, "--context", "..", "--context", ".", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]string{
+		"__.out": "result from ..\n",
+		"_.out":  "result from .\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: want %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dir)); len(entries) != 1 {
+		t.Errorf("expected nothing written beside the output dir, got %v", entries)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// planVersion is the format version written to and accepted from plan
+// files.
+const planVersion = 1
+
+// plan is the reviewable record --plan-out writes and apply-plan executes:
+// the exact kubectl invocation for each context, plus the pattern and flags
+// that produced them, for auditing.
+type plan struct {
+	Version  int           `json:"version"`
+	Created  time.Time     `json:"created"`
+	Pattern  string        `json:"pattern,omitempty"`
+	Flags    []string      `json:"flags,omitempty"`
+	Commands []planCommand `json:"commands"`
+}
+
+// planCommand is one context's kubectl invocation. Env holds KUBECONFIG in
+// full but only the names of the other variables, so a plan file carries no
+// secrets; apply-plan takes their values from its own environment.
+type planCommand struct {
+	Context string   `json:"context"`
+	Kubectl string   `json:"kubectl"`
+	Args    []string `json:"args"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// plannedFlags are the flags a plan captures in full: they choose the
+// contexts or shape the recorded kubectl commands. apply-plan only runs the
+// commands, so --plan-out rejects any other flag rather than drop it.
+var plannedFlags = map[string]bool{
+	"plan-out": true, "context": true, "interactive": true, "tag": true,
+	"tags-file": true, "require": true, "contexts-file": true,
+	"contexts-file-format": true, "fail-on-empty": true, "match-field": true,
+	"any-of": true, "exact": true, "ignore-case": true, "since": true,
+	"order-file": true, "order-strict": true, "current-first": true,
+	"dedupe-by-server": true, "limit": true, "shuffle": true, "sample": true,
+	"seed": true, "skip-unreachable": true, "show-targets": true,
+	"force-dangerous": true, "group-by": true, "group-cmd": true,
+	"group-by-namespace": true, "expand-args": true, "kubeconfig": true,
+	"kubeconfig-map": true, "kubeconfig-dir": true, "context-cache-ttl": true,
+	"no-cache": true, "kubectl-bin": true, "env": true, "env-map": true,
+	"kube-flag": true, "as": true, "as-group": true, "config": true,
+	"log-level": true, "log-format": true,
+}
+
+// checkPlanFlags returns an error naming the first flag set on the command
+// line that apply-plan would not replay.
+func checkPlanFlags(flags *pflag.FlagSet) error {
+	var unplanned []string
+	flags.Visit(func(f *pflag.Flag) {
+		if !plannedFlags[f.Name] {
+			unplanned = append(unplanned, "--"+f.Name)
+		}
+	})
+	if len(unplanned) > 0 {
+		sort.Strings(unplanned)
+		return fmt.Errorf("--plan-out records only the kubectl commands, which apply-plan runs without %s; drop it or run the command directly", unplanned[0])
+	}
+	return nil
+}
+
+// changedFlags returns the flags set on the command line as sorted
+// "--name=value" strings, leaving out --plan-out itself. --env and
+// --env-map are recorded without their values.
+func changedFlags(flags *pflag.FlagSet) []string {
+	var set []string
+	flags.Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case "plan-out":
+		case "env", "env-map":
+			values, _ := flags.GetStringArray(f.Name)
+			for i, v := range values {
+				values[i] = envKey(v, f.Name == "env-map")
+			}
+			set = append(set, "--"+f.Name+"=["+strings.Join(values, ",")+"]")
+		default:
+			set = append(set, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	sort.Strings(set)
+	return set
+}
+
+// envKey drops the value from a --env KEY=VALUE pair, or from a --env-map
+// contextRegex=KEY=VALUE entry when mapped is set.
+func envKey(v string, mapped bool) string {
+	if mapped {
+		re, kv, _ := strings.Cut(v, "=")
+		return re + "=" + envKey(kv, false)
+	}
+	key, _, _ := strings.Cut(v, "=")
+	return key
+}
+
+// planEnv returns env for a plan: KUBECONFIG as is, and the names of the
+// other variables, each once.
+func planEnv(env []string) []string {
+	var recorded []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "KUBECONFIG=") {
+			kv = envKey(kv, false)
+		}
+		if !slices.Contains(recorded, kv) {
+			recorded = append(recorded, kv)
+		}
+	}
+	return recorded
+}
+
+// appliedEnv returns a plan command's environment, taking the value of each
+// recorded name from the current environment.
+func appliedEnv(env []string) ([]string, error) {
+	applied := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.Contains(kv, "=") {
+			applied = append(applied, kv)
+			continue
+		}
+		value, ok := os.LookupEnv(kv)
+		if !ok {
+			return nil, fmt.Errorf("the plan needs %s set in the environment", kv)
+		}
+		applied = append(applied, kv+"="+value)
+	}
+	return applied, nil
+}
+
+// writePlan writes the commands a run would execute in contexts to
+// opts.planOut instead of running them.
+func writePlan(pattern string, contexts, kubectlArgs []string, opts *options, errOut io.Writer) error {
+	p := plan{Version: planVersion, Created: time.Now().UTC(), Pattern: pattern, Flags: opts.planFlags}
+	for _, ctxName := range contexts {
+		p.Commands = append(p.Commands, planCommand{
+			Context: ctxName,
+			Kubectl: execConfig{bin: opts.kubectlBin}.binary(),
+			Args:    contextArgs(ctxName, argsFor(ctxName, kubectlArgs, opts), opts),
+			Env:     planEnv(envFor(ctxName, opts)),
+		})
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.planOut, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	_, _ = fmt.Fprintf(errOut, "[xctx] wrote plan for %d context(s) to %s; run it with: kubectl xctx apply-plan %s\n", len(contexts), opts.planOut, opts.planOut)
+	return nil
+}
+
+// readPlan reads and checks a plan file.
+func readPlan(path string) (*plan, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is the user's own argument
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var p plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("invalid plan %s: unsupported version %d", path, p.Version)
+	}
+	if len(p.Commands) == 0 {
+		return nil, fmt.Errorf("invalid plan %s: no commands", path)
+	}
+	return &p, nil
+}
+
+// newApplyPlanCmd returns the "apply-plan" subcommand, which runs exactly
+// the commands in a --plan-out file, one context after another.
+func newApplyPlanCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply-plan <file>",
+		Short: "Run the kubectl commands recorded by --plan-out",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := readPlan(args[0])
+			if err != nil {
+				return err
+			}
+			return applyPlan(cmd.Context(), p, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+// applyPlan runs each of p's commands in order, printing the results under
+// the default header. It runs nothing unless every variable the plan names
+// is set.
+func applyPlan(ctx context.Context, p *plan, out, errOut io.Writer) error {
+	envs := make([][]string, len(p.Commands))
+	for i, c := range p.Commands {
+		env, err := appliedEnv(c.Env)
+		if err != nil {
+			return err
+		}
+		envs[i] = env
+	}
+	opts := &options{header: "### Context: {context}"}
+	var failed []result
+	for i, c := range p.Commands {
+		start := time.Now()
+		stdout, stderr, err := kubectlRunner(ctx, execConfig{bin: c.Kubectl, env: envs[i]}, c.Args...)
+		r := result{ctxName: c.Context, stdout: stdout, stderr: stderr, err: err, duration: time.Since(start), index: i, total: len(p.Commands)}
+		printResult(r, opts, out, errOut)
+		if ctx.Err() != nil {
+			return interrupted(errOut)
+		}
+		if r.failed() {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) > 0 {
+		return newMultiError(failed)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// planTargets answers each deletion with the context it ran in.
+var planTargets = map[string]fakeResponse{"": {stdout: "deleted in {context}\n"}}
+
+func TestPlan_RoundTrip(t *testing.T) {
+	fake := useContextKubectl(t, planTargets)
+	path := filepath.Join(t.TempDir(), "plan.json")
+	_, errOut, err := runCmd(t, "--plan-out", path, "--env", "AWS_PROFILE=prod", "--env-map", "eu=REGION=eu-west-1", "prod", "delete", "pod", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.commands) != 0 {
+		t.Fatalf("expected --plan-out not to run anything, got %q", fake.commands)
+	}
+	if !strings.Contains(errOut, "[xctx] wrote plan for 2 context(s) to "+path) {
+		t.Errorf("expected the plan to be reported, got %q", errOut)
+	}
+
+	p, err := readPlan(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Pattern != "prod" || strings.Join(p.Flags, " ") != "--env-map=[eu=REGION] --env=[AWS_PROFILE]" {
+		t.Errorf("expected the pattern and flags to be recorded without env values, got %q %q", p.Pattern, p.Flags)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "=prod") || strings.Contains(string(data), "eu-west-1") {
+		t.Errorf("expected no env values in the plan, got %s", data)
+	}
+
+	if _, _, err := runCmd(t, "apply-plan", path); err == nil || err.Error() != "the plan needs AWS_PROFILE set in the environment" {
+		t.Fatalf("expected apply-plan to require the recorded variables, got %v", err)
+	}
+	if len(fake.commands) != 0 {
+		t.Fatalf("expected nothing to run without the variables, got %q", fake.commands)
+	}
+	t.Setenv("AWS_PROFILE", "prod")
+	t.Setenv("REGION", "eu-west-1")
+	out, _, err := runCmd(t, "apply-plan", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"kubectl --context prod-us-east delete pod web AWS_PROFILE=prod",
+		"kubectl --context prod-eu-west delete pod web AWS_PROFILE=prod,REGION=eu-west-1",
+	}
+	if strings.Join(fake.commands, "|") != strings.Join(want, "|") {
+		t.Errorf("want calls %q, got %q", want, fake.commands)
+	}
+	if !strings.Contains(out, "### Context: prod-eu-west\ndeleted in prod-eu-west\n") {
+		t.Errorf("expected each result under its header, got %q", out)
+	}
+}
+
+func TestPlan_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"garbage.json": "not json",
+		"version.json": `{"version": 2, "commands": [{"context": "a", "args": ["get", "pods"]}]}`,
+		"empty.json":   `{"version": 1, "commands": []}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := runCmd(t, "apply-plan", path); err == nil || !strings.HasPrefix(err.Error(), "invalid plan ") {
+			t.Errorf("%s: expected an invalid plan error, got %v", name, err)
+		}
+	}
+}
+
+func TestPlan_RejectsFlagsNotReplayed(t *testing.T) {
+	useContextKubectl(t, planTargets)
+	path := filepath.Join(t.TempDir(), "plan.json")
+	for _, flag := range []string{"--timeout=30s", "--retries=2", "--expect=ok", "--after-each=true"} {
+		_, _, err := runCmd(t, "--plan-out", path, flag, "prod", "delete", "pod", "web")
+		if err == nil || !strings.HasPrefix(err.Error(), "--plan-out records only the kubectl commands") {
+			t.Errorf("%s: expected the flag to be rejected, got %v", flag, err)
+		}
+	}
+}
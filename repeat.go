@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// runRepeated runs kubectlArgs across contexts opts.repeat times, in the
+// configured mode, then prints how many runs passed in each context. A
+// context fails if any of its runs did. With --repeat-quiet only the
+// summary is printed.
+func runRepeated(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	runOut, runErrOut := out, errOut
+	if opts.repeatQuiet {
+		runOut, runErrOut = io.Discard, io.Discard
+	}
+
+	failures := make(map[string]int, len(contexts))
+	// --timings and --summary-json get one result per context: its last
+	// failure, or else its last run.
+	var kept map[string]result
+	if opts.finished != nil {
+		kept = make(map[string]result, len(contexts))
+		defer func() { *opts.finished = keptResults(contexts, kept) }()
+	}
+	for i := 1; i <= opts.repeat; i++ {
+		_, _ = fmt.Fprintf(runErrOut, "[xctx] run %d/%d\n", i, opts.repeat)
+		var err error
+		if opts.parallel {
+			err = runParallel(ctx, contexts, kubectlArgs, opts, runOut, runErrOut)
+		} else {
+			err = runSequential(ctx, contexts, kubectlArgs, opts, runOut, runErrOut)
+		}
+		if kept != nil {
+			for _, r := range *opts.finished {
+				if prev, ok := kept[r.ctxName]; !ok || r.err != nil || prev.err == nil {
+					kept[r.ctxName] = r
+				}
+			}
+			*opts.finished = nil
+		}
+		if errors.Is(err, errInterrupted) {
+			return err
+		}
+		var m *MultiError
+		if errors.As(err, &m) {
+			for _, e := range m.Errors {
+				failures[e.Context]++
+			}
+		}
+		// --fail-fast and --max-failures wrap the MultiError; they stop the
+		// repetitions too.
+		if _, bare := err.(*MultiError); err != nil && !bare {
+			return err
+		}
+	}
+
+	failed := &MultiError{}
+	_, _ = fmt.Fprintln(errOut, "[xctx] repeat summary:")
+	for _, name := range contexts {
+		_, _ = fmt.Fprintf(errOut, "%s: %d/%d ok\n", displayName(name, opts), opts.repeat-failures[name], opts.repeat)
+		if failures[name] > 0 {
+			failed.Errors = append(failed.Errors, &ContextError{Context: name, Err: fmt.Errorf("%d of %d runs failed", failures[name], opts.repeat)})
+		}
+	}
+	if len(failed.Errors) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// keptResults returns the results in kept in the order of contexts.
+func keptResults(contexts []string, kept map[string]result) []result {
+	var results []result
+	for _, name := range contexts {
+		if r, ok := kept[name]; ok {
+			results = append(results, r)
+		}
+	}
+	return results
+}
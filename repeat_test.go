@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// everyOtherRunFails makes prod-eu-west fail its second and fourth runs.
+var everyOtherRunFails = map[string]fakeResponse{
+	"prod-eu-west": {stdout: "ok {context}\n", nth: map[int]fakeResponse{
+		2: {stderr: "timeout\n", err: errors.New("exit status 1")},
+		4: {stderr: "timeout\n", err: errors.New("exit status 1")},
+	}},
+	"": {stdout: "ok {context}\n"},
+}
+
+func TestRepeat_CountsPassesPerContext(t *testing.T) {
+	useContextKubectl(t, everyOtherRunFails)
+	out, errOut, err := runCmd(t, "--repeat", "5", "prod", "get", "pods")
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-eu-west" {
+		t.Errorf("want prod-eu-west to fail, got %q", got)
+	}
+	if n := strings.Count(out, "ok prod-us-east"); n != 5 {
+		t.Errorf("want 5 runs' output for prod-us-east, got %d in %q", n, out)
+	}
+	for _, want := range []string{"prod-us-east: 5/5 ok\n", "prod-eu-west: 3/5 ok\n"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("expected summary line %q, got %q", want, errOut)
+		}
+	}
+	if !strings.Contains(err.Error(), "1 context(s) failed") || !strings.Contains(errOut, "[xctx] run 5/5") {
+		t.Errorf("unexpected error %v or stderr %q", err, errOut)
+	}
+}
+
+func TestRepeat_Quiet(t *testing.T) {
+	useContextKubectl(t, everyOtherRunFails)
+	out, errOut, _ := runCmd(t, "--repeat", "4", "--repeat-quiet", "--parallel", "prod", "get", "pods")
+	if out != "" {
+		t.Errorf("expected no run output with --repeat-quiet, got %q", out)
+	}
+	want := "[xctx] repeat summary:\nprod-us-east: 4/4 ok\nprod-eu-west: 2/4 ok\n"
+	if errOut != want {
+		t.Errorf("want only the summary %q, got %q", want, errOut)
+	}
+}
+
+func TestRepeat_Validation(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--repeat", "0", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "at least 1") {
+		t.Errorf("expected --repeat 0 to be rejected, got %v", err)
+	}
+	if _, _, err := runCmd(t, "--repeat-quiet", "prod", "get", "pods"); err == nil || err.Error() != "--repeat-quiet requires --repeat" {
+		t.Errorf("expected --repeat-quiet to require --repeat, got %v", err)
+	}
+}
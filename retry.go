@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// retryInContext runs args in ctxName, re-running a failed attempt up to
+// --retries times, --interval apart. Each attempt has its own --timeout, so
+// one that timed out is retried like any other failure. With --retry-on,
+// only failures whose stderr or error matches are retried; any other
+// failure is final at once.
+func retryInContext(ctx context.Context, ctxName string, args []string, opts *options) result {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		r := runAttempt(ctx, ctxName, args, opts)
+		r.duration = time.Since(start)
+		if r.err == nil || ctx.Err() != nil {
+			return r
+		}
+		if attempt > opts.retries || !retryable(r, opts) {
+			if attempt > 1 {
+				r.err = fmt.Errorf("failed after %d attempts: %w", attempt, r.err)
+			}
+			return r
+		}
+		opts.log().Info("retrying context", "context", ctxName, "attempt", attempt, "error", r.err)
+
+		select {
+		case <-watchAfter(opts.interval):
+		case <-ctx.Done():
+			return r
+		}
+	}
+}
+
+// retryable reports whether the failed result r may be retried under
+// --retry-on.
+func retryable(r result, opts *options) bool {
+	if opts.retryOn == nil {
+		return true
+	}
+	return opts.retryOn.Match(r.stderr) || opts.retryOn.MatchString(r.err.Error())
+}
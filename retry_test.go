@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyContexts fails prod-us-east with a connection error and prod-eu-west
+// with a permission error; the others succeed.
+var flakyContexts = map[string]fakeResponse{
+	"prod-us-east": {stderr: "Unable to connect to the server: net/http: TLS handshake timeout\n", err: errors.New("exit status 1")},
+	"prod-eu-west": {stderr: `Error from server (Forbidden): pods is forbidden`, err: errors.New("exit status 1")},
+	"":             {stdout: "ok\n"},
+}
+
+func TestRetries_RetryOnMatchesOnlyTransientErrors(t *testing.T) {
+	instantWatchClock(t)
+	calls := useContextKubectl(t, flakyContexts).calls
+	_, errOut, err := runCmd(t, "--parallel", "--retries", "2", "--retry-on", "TLS handshake timeout|connection refused", "prod", "get", "pods")
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-us-east,prod-eu-west" {
+		t.Errorf("want both contexts to fail, got %q", got)
+	}
+	if calls["prod-us-east"] != 3 || calls["prod-eu-west"] != 1 {
+		t.Errorf("want 3 attempts for the timeout and 1 for forbidden, got %v", calls)
+	}
+	if !strings.Contains(errOut, `context "prod-us-east" failed: failed after 3 attempts: exit status 1`) {
+		t.Errorf("expected the attempt count in the failure, got %q", errOut)
+	}
+}
+
+func TestRetries_WithoutRetryOnRetriesEverything(t *testing.T) {
+	instantWatchClock(t)
+	calls := useContextKubectl(t, flakyContexts).calls
+	_, _, _ = runCmd(t, "--retries", "1", "prod", "get", "pods")
+	if calls["prod-us-east"] != 2 || calls["prod-eu-west"] != 2 {
+		t.Errorf("want 2 attempts per failing context, got %v", calls)
+	}
+}
+
+func TestRetries_SuccessIsNotRetried(t *testing.T) {
+	calls := useContextKubectl(t, flakyContexts).calls
+	if _, _, err := runCmd(t, "--retries", "3", "staging", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls["staging-us"] != 1 {
+		t.Errorf("want a single attempt, got %d", calls["staging-us"])
+	}
+}
+
+func TestRetries_TimedOutAttemptIsRetried(t *testing.T) {
+	instantWatchClock(t)
+	fake := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "ok\n", nth: map[int]fakeResponse{1: {delay: time.Hour}}}})
+	out, _, err := runCmd(t, "--timeout", "20ms", "--retries", "1", "prod-us-east", "get", "pods")
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if fake.total != 2 || !strings.Contains(out, "ok\n") {
+		t.Errorf("want a second attempt after the timeout, got %d calls and %q", fake.total, out)
+	}
+}
+
+func TestRetryOn_RequiresRetries(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--retry-on", "timeout", "prod", "get", "pods"); err == nil || err.Error() != "--retry-on requires --retries" {
+		t.Errorf("expected --retry-on to require --retries, got %v", err)
+	}
+}
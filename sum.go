@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// summer totals the numbers --sum or --sum-regex extracts from each
+// context's output.
+type summer struct {
+	column int            // 1-based whitespace-separated column, for --sum
+	re     *regexp.Regexp // for --sum-regex; the first group, if any, is the number
+	total  float64
+}
+
+// add adds the numbers in r's output to the total. Values that do not parse
+// as numbers are reported on errOut and skipped. Failed contexts are left
+// out.
+func (s *summer) add(r result, opts *options, errOut io.Writer) {
+	if r.failed() {
+		return
+	}
+	for _, v := range s.extract(string(r.stdout)) {
+		n, err := strconv.ParseFloat(strings.ReplaceAll(v, ",", ""), 64)
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "[xctx] context %q: skipping non-numeric value %q\n", displayName(r.ctxName, opts), v)
+			continue
+		}
+		s.total += n
+	}
+}
+
+// extract returns the values to sum from stdout: the column from every
+// line that has it, or every regex match.
+func (s *summer) extract(stdout string) []string {
+	var values []string
+	if s.re != nil {
+		for _, m := range s.re.FindAllStringSubmatch(stdout, -1) {
+			values = append(values, m[len(m)-1])
+		}
+		return values
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) >= s.column {
+			values = append(values, fields[s.column-1])
+		}
+	}
+	return values
+}
+
+// print writes the "total: <n>" line ending the run.
+func (s *summer) print(out io.Writer) {
+	_, _ = fmt.Fprintf(out, "total: %s\n", strconv.FormatFloat(s.total, 'f', -1, 64))
+}
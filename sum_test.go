@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// nodeCounts answers with a small "NAME CPU" table per context: two rows
+// for prod-us-east, one for the others.
+var nodeCounts = map[string]fakeResponse{
+	"prod-us-east": {stdout: "NAME CPU\nnode-a 4\nnode-b 8\n"},
+	"":             {stdout: "NAME CPU\nnode-c 2.5\n"},
+}
+
+func TestSum_Column(t *testing.T) {
+	useContextKubectl(t, nodeCounts)
+	out, errOut, err := runCmd(t, "--sum", "2", "--header", "", "prod", "get", "nodes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out, "node-c 2.5\ntotal: 14.5\n") {
+		t.Errorf("expected a total after the output, got %q", out)
+	}
+	if !strings.Contains(errOut, `[xctx] context "prod-us-east": skipping non-numeric value "CPU"`) {
+		t.Errorf("expected the header row to be skipped with a warning, got %q", errOut)
+	}
+}
+
+func TestSum_Regex(t *testing.T) {
+	useContextKubectl(t, nodeCounts)
+	out, errOut, err := runCmd(t, "--sum-regex", `node-\w (\S+)`, "--header", "", "prod", "get", "nodes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out, "total: 14.5\n") || errOut != "" {
+		t.Errorf("expected the matched values summed without warnings, got %q / %q", out, errOut)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Context statuses in the --summary-json file.
+const (
+	statusOK      = "ok"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+)
+
+// runSummary is the --summary-json report of a run.
+type runSummary struct {
+	Contexts   int              `json:"contexts"`
+	Matched    int              `json:"matched,omitempty"` // before --sample
+	Succeeded  int              `json:"succeeded"`
+	Failed     int              `json:"failed"`
+	Skipped    int              `json:"skipped"`
+	NotRun     int              `json:"notRun"`
+	DurationMs int64            `json:"durationMs"`
+	Results    []contextSummary `json:"results"`
+}
+
+// contextSummary is one context's entry in runSummary.
+type contextSummary struct {
+	Context    string `json:"context"`
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exitCode"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// writeSummary writes the --summary-json file for a run over contexts that
+// took elapsed and finished with results. Contexts without a result, for
+// example after --fail-fast, count as not run. matched is the number of
+// contexts --sample drew from, or 0 without it.
+func writeSummary(path string, contexts []string, matched int, results []result, elapsed time.Duration) error {
+	s := runSummary{Contexts: len(contexts), Matched: matched, DurationMs: elapsed.Milliseconds(), Results: []contextSummary{}}
+	ran := map[string]bool{}
+	for _, r := range results {
+		ran[r.ctxName] = true
+		c := contextSummary{Context: r.ctxName, Status: statusOK, ExitCode: exitCode(r.err), DurationMs: r.duration.Milliseconds()}
+		switch {
+		case r.skipped:
+			c.Status = statusSkipped
+			s.Skipped++
+		case r.err != nil:
+			c.Status = statusFailed
+			s.Failed++
+		default:
+			s.Succeeded++
+		}
+		if r.err != nil {
+			c.Error = r.err.Error()
+		}
+		s.Results = append(s.Results, c)
+	}
+	for _, c := range contexts {
+		if !ran[c] {
+			s.NotRun++
+		}
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write --summary-json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryJSON_AlongsideTextOutput(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-eu-west": {stderr: "boom\n", err: errors.New("connection refused")},
+		"":             {stdout: "result from {context}\n"},
+	})
+	path := filepath.Join(t.TempDir(), "summary.json")
+	out, _, err := runCmd(t, "--summary-json", path, ".", "get", "pods")
+	if got := strings.Join(failedContexts(t, err), ","); got != "prod-eu-west" {
+		t.Errorf("want prod-eu-west to fail, got %q", got)
+	}
+	if !strings.Contains(out, "### Context: staging-us\nresult from staging-us\n") {
+		t.Errorf("expected the usual text output, got %q", out)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s runSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("invalid summary JSON: %v\n%s", err, data)
+	}
+	if s.Contexts != 4 || s.Succeeded != 3 || s.Failed != 1 || s.Skipped != 0 || s.NotRun != 0 || len(s.Results) != 4 {
+		t.Errorf("unexpected counts: %+v", s)
+	}
+	if r := s.Results[1]; r.Context != "prod-eu-west" || r.Status != statusFailed || r.ExitCode != -1 || r.Error != "connection refused" {
+		t.Errorf("unexpected entry for the failed context: %+v", r)
+	}
+}
+
+func TestSummaryJSON_CountsNotRun(t *testing.T) {
+	useFailingKubectl(t)
+	path := filepath.Join(t.TempDir(), "summary.json")
+	_, _, _ = runCmd(t, "--summary-json", path, "--fail-fast", ".", "get", "pods")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s runSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Failed != 1 || s.NotRun != 3 {
+		t.Errorf("expected one failure and three contexts not run, got %+v", s)
+	}
+}
+
+// readSummary reads the --summary-json file at path.
+func readSummary(t *testing.T, path string) runSummary {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s runSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("invalid summary JSON: %v\n%s", err, data)
+	}
+	return s
+}
+
+func TestSummaryJSON_Diff(t *testing.T) {
+	useFakeKubectl(t)
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if _, _, err := runCmd(t, "--summary-json", path, "--diff", "prod", "get", "pods"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := readSummary(t, path); s.Contexts != 2 || s.Succeeded != 2 || s.NotRun != 0 || len(s.Results) != 2 {
+		t.Errorf("expected both diffed contexts in the summary, got %+v", s)
+	}
+}
+
+func TestSummaryJSON_RepeatReportsEachContextOnce(t *testing.T) {
+	// prod-eu-west fails only on its second run.
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-eu-west": {stdout: "ok\n", nth: map[int]fakeResponse{2: {err: errors.New("connection refused")}}},
+		"":             {stdout: "ok\n"},
+	})
+	path := filepath.Join(t.TempDir(), "summary.json")
+	_, _, _ = runCmd(t, "--summary-json", path, "--repeat", "3", "prod", "get", "pods")
+	s := readSummary(t, path)
+	if s.Contexts != 2 || s.Succeeded != 1 || s.Failed != 1 || len(s.Results) != 2 {
+		t.Errorf("expected one entry per context, got %+v", s)
+	}
+	if r := s.Results[1]; r.Context != "prod-eu-west" || r.Error != "connection refused" {
+		t.Errorf("expected prod-eu-west's failure to be kept, got %+v", r)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTagsPath returns tags.yaml next to the default config file.
+func defaultTagsPath() string {
+	if config := defaultConfigPath(); config != "" {
+		return filepath.Join(filepath.Dir(config), "tags.yaml")
+	}
+	return ""
+}
+
+// taggedContexts returns the contexts that the tags file at path gives
+// every one of tags, in file order. The file maps context names to lists
+// of tags:
+//
+//	prod-us-east: [prod, us]
+//	dev-local: [dev]
+func taggedContexts(path string, tags []string) ([]string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is the user's own flag value
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --tags-file: %w", err)
+	}
+	// Decode into a node rather than a map to keep the file's order.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid --tags-file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("--tags-file %s is empty", path)
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid --tags-file %s: %w", path, errors.New("expected a map of context names to tag lists"))
+	}
+
+	var names []string
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		var have []string
+		if err := root.Content[i+1].Decode(&have); err != nil {
+			return nil, fmt.Errorf("invalid --tags-file %s: tags of %q: %w", path, root.Content[i].Value, err)
+		}
+		if hasAll(have, tags) {
+			names = append(names, root.Content[i].Value)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no contexts tagged %s in %s", strings.Join(tags, " and "), path)
+	}
+	return names, nil
+}
+
+// hasAll reports whether have contains every one of want.
+func hasAll(have, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(have, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTagsFile writes a tags file tagging the fake contexts and returns
+// its path.
+func writeTagsFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tags.yaml")
+	data := "staging-us: [nonprod, us]\nprod-us-east: [prod, us]\nprod-eu-west: [prod, eu]\ndev-local: []\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTag_SelectsTaggedContexts(t *testing.T) {
+	useFakeKubectl(t)
+	path := writeTagsFile(t)
+	for _, tc := range []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"us"}, "staging-us,prod-us-east"},
+		{[]string{"prod", "us"}, "prod-us-east"},
+	} {
+		args := []string{"--tags-file", path, "--header", ""}
+		for _, tag := range tc.tags {
+			args = append(args, "--tag", tag)
+		}
+		out, _, err := runCmd(t, append(args, "get", "pods")...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := strings.ReplaceAll(strings.TrimSuffix(strings.ReplaceAll(out, "result from ", ""), "\n"), "\n", ",")
+		if got != tc.want {
+			t.Errorf("%v: want %s, got %s", tc.tags, tc.want, got)
+		}
+	}
+}
+
+func TestTag_Errors(t *testing.T) {
+	useFakeKubectl(t)
+	path := writeTagsFile(t)
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(bad, []byte("- prod-us-east\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		file, tag, want string
+	}{
+		{path, "qa", "no contexts tagged qa in "},
+		{bad, "prod", "expected a map of context names to tag lists"},
+		{filepath.Join(t.TempDir(), "missing.yaml"), "prod", "failed to read --tags-file"},
+	} {
+		_, _, err := runCmd(t, "--tags-file", tc.file, "--tag", tc.tag, "get", "pods")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: want error containing %q, got %v", filepath.Base(tc.file), tc.want, err)
+		}
+	}
+}
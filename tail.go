@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// runTail streams a logs command from every context at once until each
+// stream ends or the run is interrupted. Lines are prefixed with the context
+// as they arrive; a stream that ends or fails does not stop the others.
+func runTail(ctx context.Context, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	verb, _, flags := parseKubectlArgs(kubectlArgs)
+	if verb != "logs" {
+		return asUsage(fmt.Errorf("--tail requires a logs command, got %q", verb))
+	}
+	if !flags["-f"] && !flags["--follow"] {
+		kubectlArgs = append(kubectlArgs[:len(kubectlArgs):len(kubectlArgs)], "--follow")
+	}
+
+	// One lock for both streams keeps lines from different contexts whole.
+	var mu sync.Mutex
+	var failed []result
+	var wg sync.WaitGroup
+	for _, ctxName := range contexts {
+		wg.Add(1)
+		go func(ctxName string) {
+			defer wg.Done()
+			name := displayName(ctxName, opts)
+			stdout := &lineWriter{mu: &mu, out: out, prefix: name}
+			stderr := &lineWriter{mu: &mu, out: errOut, prefix: name}
+			cfg := execConfig{bin: opts.kubectlBin, env: envFor(ctxName, opts)}
+			args := contextArgs(ctxName, kubectlArgs, opts)
+			if opts.echoOut != nil {
+				echoCommand(opts.echoOut, cfg, args)
+			}
+			err := kubectlStreamer(ctx, cfg, stdout, stderr, args...)
+			stdout.flush()
+			stderr.flush()
+
+			mu.Lock()
+			defer mu.Unlock()
+			// Streams killed by the interrupt have not failed.
+			if err != nil && ctx.Err() == nil {
+				r := result{ctxName: ctxName, err: err}
+				failed = append(failed, r)
+				printFailure(r, opts, errOut)
+			}
+		}(ctxName)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return newMultiError(failed)
+	}
+	return nil
+}
+
+// lineWriter writes each complete line to out as "<prefix>\t<line>", holding
+// back a partial line until the rest of it arrives.
+type lineWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	w.mu.Lock()
+	writePrefixed(w.out, w.prefix, w.buf[:i+1])
+	w.mu.Unlock()
+	w.buf = w.buf[i+1:]
+	return len(p), nil
+}
+
+// flush writes any final line that lacked a trailing newline.
+func (w *lineWriter) flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.mu.Lock()
+	writePrefixed(w.out, w.prefix, w.buf)
+	w.mu.Unlock()
+	w.buf = nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockKubectlStreamer replaces kubectlStreamer for the duration of the test.
+func mockKubectlStreamer(t *testing.T, fn func(ctx context.Context, stdout, stderr io.Writer, args ...string) error) {
+	t.Helper()
+	orig := kubectlStreamer
+	kubectlStreamer = func(ctx context.Context, _ execConfig, stdout, stderr io.Writer, args ...string) error {
+		return fn(ctx, stdout, stderr, args...)
+	}
+	t.Cleanup(func() { kubectlStreamer = orig })
+}
+
+// sortedLines returns the lines of s in sorted order, since concurrent
+// streams interleave unpredictably.
+func sortedLines(s string) []string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+// --- runTail ---
+
+func TestTail_PrefixesLinesUntilInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var finished sync.WaitGroup
+	finished.Add(2)
+	var mu sync.Mutex
+	var calls []string
+	mockKubectlStreamer(t, func(ctx context.Context, stdout, _ io.Writer, args ...string) error {
+		mu.Lock()
+		calls = append(calls, strings.Join(args, " "))
+		mu.Unlock()
+		if args[1] == "prod-us-east" {
+			// This stream ends on its own; the other keeps running.
+			defer finished.Done()
+			for _, chunk := range []string{"GET /health\nGET /ap", "i/v1\n", "POST /login"} {
+				_, _ = io.WriteString(stdout, chunk)
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		}
+		_, _ = io.WriteString(stdout, "starting\nready\n")
+		finished.Done()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	go func() {
+		finished.Wait()
+		cancel()
+	}()
+	var out, errOut strings.Builder
+	err := runTail(ctx, []string{"prod-us-east", "prod-eu-west"}, []string{"logs", "deploy/web"}, &options{}, &out, &errOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"prod-eu-west\tready",
+		"prod-eu-west\tstarting",
+		"prod-us-east\tGET /api/v1",
+		"prod-us-east\tGET /health",
+		"prod-us-east\tPOST /login",
+	}
+	if got := sortedLines(out.String()); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("want lines %q, got %q", want, got)
+	}
+	if errOut.String() != "" {
+		t.Errorf("expected no failures reported, got %q", errOut.String())
+	}
+	for _, c := range calls {
+		if !strings.HasSuffix(c, "logs deploy/web --follow") {
+			t.Errorf("expected --follow to be added, got %q", c)
+		}
+	}
+}
+
+func TestTail_FailedStreamReported(t *testing.T) {
+	mockKubectlStreamer(t, func(_ context.Context, stdout, stderr io.Writer, args ...string) error {
+		if args[1] == "staging-us" {
+			_, _ = io.WriteString(stderr, "error: deployments.apps \"web\" not found\n")
+			return errors.New("exit status 1")
+		}
+		_, _ = io.WriteString(stdout, "ok\n")
+		return nil
+	})
+	var out, errOut strings.Builder
+	err := runTail(context.Background(), []string{"prod-us-east", "staging-us"}, []string{"logs", "-f", "deploy/web"}, &options{}, &out, &errOut)
+	if got := strings.Join(failedContexts(t, err), ","); got != "staging-us" {
+		t.Errorf("want staging-us to fail, got %q", got)
+	}
+	if out.String() != "prod-us-east\tok\n" {
+		t.Errorf("expected the healthy stream's output, got %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "staging-us\terror: deployments.apps \"web\" not found\n") ||
+		!strings.Contains(errOut.String(), `[xctx] context "staging-us" failed: exit status 1`) {
+		t.Errorf("expected prefixed stderr and a failure line, got %q", errOut.String())
+	}
+}
+
+func TestTail_RequiresLogs(t *testing.T) {
+	useFakeKubectl(t)
+	_, _, err := runCmd(t, "--tail", "prod", "get", "pods")
+	if err == nil || !strings.Contains(err.Error(), "--tail requires a logs command") {
+		t.Errorf("expected a logs-only error, got %v", err)
+	}
+}
+
+func TestTail_NamespaceBeforeLogs(t *testing.T) {
+	var got []string
+	mockKubectlStreamer(t, func(_ context.Context, _, _ io.Writer, args ...string) error {
+		got = args
+		return nil
+	})
+	var out, errOut strings.Builder
+	err := runTail(context.Background(), []string{"prod-us-east"}, []string{"-n", "web", "logs", "deploy/x"}, &options{}, &out, &errOut)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "--context prod-us-east -n web logs deploy/x --follow"; strings.Join(got, " ") != want {
+		t.Errorf("want %q, got %q", want, strings.Join(got, " "))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// formatDuration renders a context's run time for {duration} and --timings.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// printTimings writes the --timings table: one "<context>  <duration>" row
+// per result, slowest first.
+func printTimings(results []result, opts *options, errOut io.Writer) {
+	sorted := append([]result(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].duration > sorted[j].duration })
+
+	_, _ = fmt.Fprintln(errOut, "[xctx] timings (slowest first):")
+	tw := tabwriter.NewWriter(errOut, 0, 0, 2, ' ', 0)
+	for _, r := range sorted {
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", displayName(r.ctxName, opts), formatDuration(r.duration))
+	}
+	_ = tw.Flush()
+}
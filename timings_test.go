@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimings_SlowestFirst(t *testing.T) {
+	useContextKubectl(t, map[string]fakeResponse{
+		"prod-eu-west": {stdout: "ok\n", delay: 40 * time.Millisecond},
+		"staging-us":   {stdout: "ok\n", delay: 20 * time.Millisecond},
+		"":             {stdout: "ok\n"},
+	})
+	out, errOut, err := runCmd(t, "--timings", "prod|staging", "get", "pods")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "timings") {
+		t.Errorf("expected the timings on stderr only, got stdout %q", out)
+	}
+	_, table, ok := strings.Cut(errOut, "[xctx] timings (slowest first):\n")
+	if !ok {
+		t.Fatalf("expected a timings table, got %q", errOut)
+	}
+	var order []string
+	for _, line := range strings.Split(strings.TrimSpace(table), "\n") {
+		order = append(order, strings.Fields(line)[0])
+	}
+	if want := "prod-eu-west staging-us prod-us-east"; strings.Join(order, " ") != want {
+		t.Errorf("want rows %q, got %q", want, order)
+	}
+}
+
+func TestTimings_Off(t *testing.T) {
+	useFakeKubectl(t)
+	_, errOut, _ := runCmd(t, "prod", "get", "pods")
+	if strings.Contains(errOut, "timings") {
+		t.Errorf("expected no timings without --timings, got %q", errOut)
+	}
+}
+
+func TestHeader_DurationPlaceholder(t *testing.T) {
+	r := result{ctxName: "prod-us-east", duration: 1234567 * time.Microsecond}
+	if got := renderHeader(r, &options{header: "{context} took {duration}"}); got != "prod-us-east took 1.235s" {
+		t.Errorf("unexpected header %q", got)
+	}
+}
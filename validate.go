@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// probeContexts sends each context's API server a lightweight version
+// request, giving up on a server after requestTimeout. A result without an
+// error means the context is reachable.
+func probeContexts(ctx context.Context, contexts []string, requestTimeout time.Duration, opts *options) []result {
+	args := []string{"version", "--request-timeout=" + requestTimeout.String(), "-o", "json"}
+	return collectResults(ctx, contexts, args, plainRunOptions(opts))
+}
+
+// runValidate checks that every context's API server answers, printing one
+// "<context>\treachable" or "<context>\tunreachable\t<reason>" line each.
+func runValidate(ctx context.Context, contexts []string, opts *options, out, errOut io.Writer) error {
+	results := probeContexts(ctx, contexts, 3*time.Second, opts)
+	if ctx.Err() != nil {
+		return interrupted(errOut)
+	}
+
+	var unreachable int
+	for _, r := range results {
+		name := displayName(r.ctxName, opts)
+		if r.err == nil {
+			_, _ = fmt.Fprintf(out, "%s\treachable\n", name)
+			continue
+		}
+		unreachable++
+		_, _ = fmt.Fprintf(out, "%s\tunreachable\t%s\n", name, unreachableReason(r))
+	}
+	if unreachable > 0 {
+		return fmt.Errorf("%d of %d context(s) unreachable", unreachable, len(results))
+	}
+	return nil
+}
+
+// unreachableReason returns the first line of kubectl's stderr, which names
+// the connection problem, falling back to the exec error.
+func unreachableReason(r result) string {
+	if msg, _, _ := strings.Cut(strings.TrimSpace(string(r.stderr)), "\n"); msg != "" {
+		return msg
+	}
+	return r.err.Error()
+}
+
+// dropUnreachable probes contexts for --skip-unreachable and returns those
+// that answered, listing the others on errOut.
+func dropUnreachable(ctx context.Context, contexts []string, opts *options, errOut io.Writer) []string {
+	var reachable []string
+	for _, r := range probeContexts(ctx, contexts, 2*time.Second, opts) {
+		if r.err != nil {
+			_, _ = fmt.Fprintf(errOut, "[xctx] skipping unreachable context %q: %s\n", r.ctxName, unreachableReason(r))
+			continue
+		}
+		reachable = append(reachable, r.ctxName)
+	}
+	return reachable
+}
+
+// plainRunOptions returns a copy of opts for xctx's own kubectl calls, such
+// as probes and diagnostics, which are not held to the run's output checks,
+// hooks or failure limits.
+func plainRunOptions(opts *options) *options {
+	plain := *opts
+	plain.expect, plain.expectRegex, plain.waitFor = "", nil, 0
+	plain.retries, plain.retryOn = 0, nil
+	plain.jq, plain.resultCache = nil, nil
+	plain.strictJSON, plain.stdinData = false, nil
+	plain.failOnStderr = false
+	plain.beforeEach, plain.afterEach = "", ""
+	plain.failFast, plain.abortAfter = false, 0
+	plain.finished, plain.sum = nil, nil
+	return &plain
+}
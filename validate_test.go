@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// unreachableStaging answers "kubectl version" in every context except
+// staging-us, whose API server is unreachable.
+var unreachableStaging = map[string]fakeResponse{
+	"staging-us": {stderr: "Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\n", err: errors.New("exit status 1")},
+	"":           {stdout: `{"serverVersion":{"gitVersion":"v1.30.0"}}`},
+}
+
+// --- --validate ---
+
+func TestValidate_ReportsReachability(t *testing.T) {
+	fake := useContextKubectl(t, unreachableStaging)
+	out, _, err := runCmd(t, "--validate", ".")
+	if err == nil || err.Error() != "1 of 4 context(s) unreachable" {
+		t.Errorf("want unreachable summary error, got %v", err)
+	}
+	want := "prod-us-east\treachable\n" +
+		"prod-eu-west\treachable\n" +
+		"staging-us\tunreachable\tUnable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout\n" +
+		"dev-local\treachable\n"
+	if out != want {
+		t.Errorf("unexpected output:\ngot:\n%s\nwant:\n%s", out, want)
+	}
+	for _, c := range fake.commands {
+		if !strings.HasSuffix(c, " version --request-timeout=3s -o json") {
+			t.Errorf("expected only version probes, got %q", c)
+		}
+	}
+}
+
+func TestValidate_IgnoresCommandAndSucceedsWhenAllReachable(t *testing.T) {
+	fake := useContextKubectl(t, unreachableStaging)
+	out, _, err := runCmd(t, "--validate", "--parallel", "prod", "delete", "ns", "default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fake.total != 2 {
+		t.Errorf("want 2 probes, got %q", fake.commands)
+	}
+	if strings.Count(out, "\treachable\n") != 2 {
+		t.Errorf("expected both prod contexts reachable, got %q", out)
+	}
+}
+
+// --- --skip-unreachable ---
+
+func TestSkipUnreachable_DropsFailedPreflight(t *testing.T) {
+	fake := useContextKubectl(t, unreachableStaging)
+	out, errOut, err := runCmd(t, "--skip-unreachable", "--header", "{context}", "prod|staging", "get", "ns")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(errOut, `[xctx] skipping unreachable context "staging-us": Unable to connect to the server`) {
+		t.Errorf("expected staging-us listed as skipped, got %q", errOut)
+	}
+	if strings.Contains(out, "staging-us") {
+		t.Errorf("expected staging-us left out of the run, got %q", out)
+	}
+	// Every context is probed first; only the reachable ones then run.
+	for i, c := range fake.commands {
+		want := " version --request-timeout=2s -o json"
+		if i >= 3 {
+			want = " get ns"
+		}
+		if !strings.HasSuffix(c, want) {
+			t.Errorf("call %d: want %q, got %q", i+1, want, c)
+		}
+	}
+	if fake.total != 5 || fake.calls["staging-us"] != 1 {
+		t.Errorf("want 3 probes and 2 runs, staging-us probed only, got %q", fake.commands)
+	}
+}
+
+func TestSkipUnreachable_AllUnreachable(t *testing.T) {
+	useContextKubectl(t, unreachableStaging)
+	if _, _, err := runCmd(t, "--skip-unreachable", "staging", "get", "ns"); err == nil || err.Error() != "all 1 context(s) unreachable" {
+		t.Errorf("expected an all-unreachable error, got %v", err)
+	}
+}
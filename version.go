@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// latestReleaseURL is the GitHub API endpoint for the newest release.
+// Overridable in tests.
+var latestReleaseURL = "https://api.github.com/repos/be0x74a/kubectl-xctx/releases/latest"
+
+// newVersionCmd returns the "version" subcommand. It prints the build's
+// version, and with --check also asks GitHub for the latest release; the
+// network call is opt-in so the default stays offline, like --version.
+func newVersionCmd() *cobra.Command {
+	var check bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the kubectl-xctx version, optionally checking for a newer release",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out := cmd.OutOrStdout()
+			if !check {
+				_, _ = fmt.Fprintln(out, version)
+				return nil
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
+			defer cancel()
+			latest, err := latestRelease(ctx)
+			if err != nil {
+				// Being offline is no reason to fail.
+				_, _ = fmt.Fprintln(out, version)
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "[xctx] could not check for updates: %v\n", err)
+				return nil
+			}
+			printVersionCheck(out, version, latest)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "Compare against the latest GitHub release (makes one HTTPS request)")
+	return cmd
+}
+
+// latestRelease returns the tag of the newest GitHub release.
+func latestRelease(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("unexpected GitHub response: %w", err)
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("unexpected GitHub response: no tag_name")
+	}
+	return release.TagName, nil
+}
+
+// printVersionCheck reports whether latest is newer than current.
+func printVersionCheck(out io.Writer, current, latest string) {
+	switch {
+	case parseVersion(current) == nil:
+		_, _ = fmt.Fprintf(out, "%s (latest release: %s)\n", current, latest)
+	case newerVersion(latest, current):
+		_, _ = fmt.Fprintf(out, "%s: update available to %s (kubectl krew upgrade xctx)\n", current, latest)
+	default:
+		_, _ = fmt.Fprintf(out, "%s: up to date\n", current)
+	}
+}
+
+// parseVersion splits a "v1.2.3" style version into its numbers, ignoring
+// any pre-release suffix. It returns nil for anything else, such as "dev".
+func parseVersion(v string) []int {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		nums[i] = n
+	}
+	return nums
+}
+
+// newerVersion reports whether version a is newer than b.
+func newerVersion(a, b string) bool {
+	va, vb := parseVersion(a), parseVersion(b)
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveLatestRelease points latestReleaseURL at a server answering with body
+// and status.
+func serveLatestRelease(t *testing.T, status int, body string) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	orig := latestReleaseURL
+	latestReleaseURL = srv.URL
+	t.Cleanup(func() { latestReleaseURL = orig })
+}
+
+// setVersion sets the build version for the test.
+func setVersion(t *testing.T, v string) {
+	t.Helper()
+	orig := version
+	version = v
+	t.Cleanup(func() { version = orig })
+}
+
+func TestVersionCheck_UpdateAvailable(t *testing.T) {
+	setVersion(t, "v1.2.0")
+	serveLatestRelease(t, http.StatusOK, `{"tag_name":"v1.10.0"}`)
+	out, _, err := runCmd(t, "version", "--check")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "v1.2.0: update available to v1.10.0 (kubectl krew upgrade xctx)\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestVersionCheck_UpToDate(t *testing.T) {
+	setVersion(t, "v1.10.0")
+	serveLatestRelease(t, http.StatusOK, `{"tag_name":"v1.10.0"}`)
+	if out, _, _ := runCmd(t, "version", "--check"); out != "v1.10.0: up to date\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestVersionCheck_OfflineIsNotAnError(t *testing.T) {
+	setVersion(t, "v1.2.0")
+	serveLatestRelease(t, http.StatusServiceUnavailable, "")
+	out, errOut, err := runCmd(t, "version", "--check")
+	if err != nil {
+		t.Fatalf("expected a failed check not to fail, got %v", err)
+	}
+	if out != "v1.2.0\n" || !strings.Contains(errOut, "could not check for updates: GitHub returned 503") {
+		t.Errorf("unexpected output %q / %q", out, errOut)
+	}
+}
+
+func TestVersion_OfflineByDefault(t *testing.T) {
+	setVersion(t, "v1.2.0")
+	orig := latestReleaseURL
+	latestReleaseURL = "http://127.0.0.1:0/unreachable"
+	t.Cleanup(func() { latestReleaseURL = orig })
+	out, errOut, err := runCmd(t, "version")
+	if err != nil || out != "v1.2.0\n" || errOut != "" {
+		t.Errorf("expected only the version, got %q / %q / %v", out, errOut, err)
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.10.0", "v1.9.3", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2", "v1.2.1", false},
+		{"v2.0.0-rc.1", "v1.9.0", true},
+	} {
+		if got := newerVersion(c.a, c.b); got != c.want {
+			t.Errorf("newerVersion(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
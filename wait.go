@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errWaitExpired is the cause of a --wait-for poll loop running out, telling
+// an attempt it cut short apart from one that hit --timeout.
+var errWaitExpired = errors.New("gave up")
+
+// waitInContext re-runs args in ctxName every --interval until an attempt
+// succeeds, which with --expect means its output matched, or until
+// --wait-for elapses. Each attempt has its own --timeout. Each context
+// waits on its own, so one that is ready stops polling while others
+// continue.
+func waitInContext(ctx context.Context, ctxName string, args []string, opts *options) result {
+	waitCtx, cancel := context.WithTimeoutCause(ctx, opts.waitFor, errWaitExpired)
+	defer cancel()
+	start := time.Now()
+	for {
+		r := runAttempt(waitCtx, ctxName, args, opts)
+		r.duration = time.Since(start)
+		if r.err == nil || ctx.Err() != nil {
+			return r
+		}
+		opts.log().Debug("waiting for context", "context", ctxName, "error", r.err)
+
+		if waitCtx.Err() == nil {
+			select {
+			case <-watchAfter(opts.interval):
+				continue
+			case <-waitCtx.Done():
+			}
+		}
+		if errors.Is(r.err, errWaitExpired) {
+			r.err = fmt.Errorf("%w after %v: the last attempt was still running", errWaitExpired, opts.waitFor)
+		} else {
+			r.err = fmt.Errorf("%w after %v: %w", errWaitExpired, opts.waitFor, r.err)
+		}
+		// A --timeout hit by an earlier attempt is not what ended the wait.
+		r.skipped = false
+		return r
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// instantWatchClock replaces watchAfter with one that fires immediately.
+func instantWatchClock(t *testing.T) {
+	t.Helper()
+	orig := watchAfter
+	watchAfter = func(time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { watchAfter = orig })
+}
+
+// rolloutAfter returns responses where prod-eu-west reports the new image
+// from its readyAfter-th call on, and every other context has it already.
+func rolloutAfter(readyAfter int) map[string]fakeResponse {
+	old := map[int]fakeResponse{}
+	for n := 1; n < readyAfter; n++ {
+		old[n] = fakeResponse{stdout: "nginx:1.25"}
+	}
+	return map[string]fakeResponse{
+		"prod-eu-west": {stdout: "nginx:1.27", nth: old},
+		"":             {stdout: "nginx:1.27"},
+	}
+}
+
+// --- --wait-for ---
+
+func TestWaitFor_PollsUntilExpectationHolds(t *testing.T) {
+	instantWatchClock(t)
+	calls := useContextKubectl(t, rolloutAfter(3)).calls
+	out, _, err := runCmd(t, "--parallel", "--wait-for", "1m", "--expect", "nginx:1.27", "prod", "get", "deploy", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls["prod-eu-west"] != 3 || calls["prod-us-east"] != 1 {
+		t.Errorf("want 3 polls of prod-eu-west and 1 of prod-us-east, got %v", calls)
+	}
+	if strings.Contains(out, "nginx:1.25") {
+		t.Errorf("expected only the final attempt's output, got %q", out)
+	}
+}
+
+func TestWaitFor_GivesUpAfterTimeout(t *testing.T) {
+	useContextKubectl(t, rolloutAfter(1000))
+	_, errOut, err := runCmd(t, "--wait-for", "50ms", "--interval", "10ms", "--expect", "nginx:1.27", "prod-eu-west", "get", "deploy", "web")
+	if err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if !strings.Contains(errOut, `context "prod-eu-west" failed: gave up after 50ms: output did not match --expect "nginx:1.27"`) {
+		t.Errorf("expected a give-up message, got %q", errOut)
+	}
+}
+
+func TestWaitFor_RequiresExpect(t *testing.T) {
+	useFakeKubectl(t)
+	if _, _, err := runCmd(t, "--wait-for", "1m", "prod", "get", "pods"); err == nil || !strings.Contains(err.Error(), "--wait-for requires --expect") {
+		t.Errorf("expected --wait-for to require --expect, got %v", err)
+	}
+}
+
+func TestWaitFor_TimeoutBoundsEachAttempt(t *testing.T) {
+	instantWatchClock(t)
+	// The first attempt hangs until its --timeout kills it.
+	fake := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "nginx:1.27", nth: map[int]fakeResponse{1: {delay: time.Hour}}}})
+	_, errOut, err := runCmd(t, "--timeout", "20ms", "--wait-for", "1m", "--expect", "nginx:1.27", "prod-eu-west", "get", "deploy", "web")
+	if err != nil {
+		t.Fatalf("expected the second attempt to succeed, got %v (%q)", err, errOut)
+	}
+	if fake.total != 2 {
+		t.Errorf("want a second attempt after the first timed out, got %d calls", fake.total)
+	}
+}
+
+func TestWaitFor_ExpiryIsNotATimeout(t *testing.T) {
+	useContextKubectl(t, untilCancelled)
+	_, errOut, err := runCmd(t, "--timeout-action", "skip", "--wait-for", "20ms", "--expect", "nginx:1.27", "prod-eu-west", "get", "deploy", "web")
+	if !errors.Is(err, errWaitExpired) {
+		t.Fatalf("expected the wait to fail with its own error, got %v", err)
+	}
+	if !strings.Contains(errOut, `context "prod-eu-west" failed: gave up after 20ms: the last attempt was still running`) || strings.Contains(errOut, "timed out") {
+		t.Errorf("expected the expiry reported apart from --timeout, got %q", errOut)
+	}
+}
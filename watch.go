@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// watchAfter waits between --watch iterations. Overridable in tests.
+var watchAfter = time.After
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// watch re-runs kubectlArgs across contexts every opts.interval until ctx is
+// cancelled. Failures are reported per iteration and do not end the loop;
+// an interrupt ends it cleanly.
+func watch(ctx context.Context, pattern string, contexts, kubectlArgs []string, opts *options, out, errOut io.Writer) error {
+	for iteration := 0; ; iteration++ {
+		if opts.watchRefresh && iteration > 0 {
+			refreshed, err := resolveContexts(pattern, opts, errOut)
+			if err != nil {
+				_, _ = fmt.Fprintf(errOut, "[xctx] %v\n", err)
+			} else {
+				contexts = refreshed
+			}
+		}
+		if !opts.noClear {
+			_, _ = fmt.Fprint(out, clearScreen)
+		}
+		err := run(ctx, contexts, kubectlArgs, opts, out, errOut)
+		if errors.Is(err, errInterrupted) {
+			return nil
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "[xctx] %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			if !interruptedRun(ctx) {
+				_, _ = fmt.Fprintln(errOut, "[xctx] --deadline reached, stopping --watch")
+			}
+			return nil
+		case <-watchAfter(opts.interval):
+		}
+	}
+}
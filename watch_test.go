@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeWatchClock replaces watchAfter so that the loop cancels ctx after n
+// waits instead of sleeping.
+func fakeWatchClock(t *testing.T, cancel context.CancelFunc, n int) {
+	t.Helper()
+	orig := watchAfter
+	var waits int
+	watchAfter = func(time.Duration) <-chan time.Time {
+		waits++
+		if waits == n {
+			cancel()
+			return nil // never fires, so ctx.Done wins
+		}
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+	t.Cleanup(func() { watchAfter = orig })
+}
+
+// --- watch ---
+
+func TestWatch_RunsUntilCancelled(t *testing.T) {
+	calls := useContextKubectl(t, map[string]fakeResponse{"": {stdout: "ok\n"}}).calls
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fakeWatchClock(t, cancel, 3)
+
+	var out, errOut strings.Builder
+	opts := &options{watch: true, interval: time.Second}
+	if err := execute(ctx, "prod", []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("expected clean exit on cancel, got: %v", err)
+	}
+	for _, c := range []string{"prod-us-east", "prod-eu-west"} {
+		if calls[c] != 3 {
+			t.Errorf("expected 3 iterations for %s, got %d", c, calls[c])
+		}
+	}
+	if strings.Count(out.String(), clearScreen) != 3 {
+		t.Errorf("expected the screen cleared before each iteration, got: %q", out.String())
+	}
+}
+
+func TestWatch_NoClearAndRefresh(t *testing.T) {
+	fake := useContextKubectl(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fakeWatchClock(t, cancel, 2)
+
+	var out, errOut strings.Builder
+	opts := &options{watch: true, noClear: true, watchRefresh: true}
+	if err := execute(ctx, "prod", []string{"get", "pods"}, opts, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), clearScreen) {
+		t.Errorf("expected no clear sequence with noClear, got: %q", out.String())
+	}
+	if fake.calls["config get-contexts"] != 2 {
+		t.Errorf("expected contexts re-resolved on the second iteration (2 listings), got %d", fake.calls["config get-contexts"])
+	}
+}
+
+func TestWatch_FailuresDoNotStopLoop(t *testing.T) {
+	calls := useFailingKubectl(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fakeWatchClock(t, cancel, 2)
+
+	var out, errOut strings.Builder
+	if err := execute(ctx, "prod-us-east", []string{"get", "pods"}, &options{watch: true}, &out, &errOut); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected the loop to continue after a failed iteration, got %d calls", *calls)
+	}
+	if !strings.Contains(errOut.String(), "[xctx] 1 context(s) failed") {
+		t.Errorf("expected per-iteration failure summary, got: %q", errOut.String())
+	}
+}
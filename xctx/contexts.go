@@ -0,0 +1,41 @@
+package xctx
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ListContexts returns every context name in the kubeconfig, in kubectl's
+// order.
+func ListContexts(ctx context.Context, runner Runner) ([]string, error) {
+	out, _, err := runner(ctx, "config", "get-contexts", "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kubectl contexts: %w", err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// MatchContexts returns the context names that re matches.
+func MatchContexts(ctx context.Context, runner Runner, re *regexp.Regexp) ([]string, error) {
+	all, err := ListContexts(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, name := range all {
+		if re.MatchString(name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
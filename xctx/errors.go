@@ -0,0 +1,77 @@
+package xctx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrOutputMismatch marks a context whose kubectl command succeeded but whose
+// output did not satisfy an expectation. MultiError names such contexts apart
+// from those that failed to run.
+var ErrOutputMismatch = errors.New("output did not match")
+
+// ErrInvalidPattern is wrapped by the error Run returns when opts.Pattern is
+// not a valid regular expression.
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// ErrExecutionFailed matches every *MultiError under errors.Is, so callers
+// can tell failed contexts apart from errors that stopped the run early.
+var ErrExecutionFailed = errors.New("execution failed")
+
+// ContextError is the failure of a single context.
+type ContextError struct {
+	Context string
+	Err     error
+}
+
+func (e *ContextError) Error() string {
+	return fmt.Sprintf("context %q: %v", e.Context, e.Err)
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned when one or more contexts fail, with one
+// ContextError per failed context in run order.
+type MultiError struct {
+	Errors []*ContextError
+}
+
+// Error summarizes the failures. When some contexts failed an expectation,
+// it names them apart from those that failed to run.
+func (m *MultiError) Error() string {
+	var mismatched, errored []string
+	for _, e := range m.Errors {
+		if errors.Is(e.Err, ErrOutputMismatch) {
+			mismatched = append(mismatched, e.Context)
+		} else {
+			errored = append(errored, e.Context)
+		}
+	}
+	msg := fmt.Sprintf("%d context(s) failed", len(m.Errors))
+	if len(mismatched) == 0 {
+		return msg
+	}
+	msg += "; output did not match in: " + strings.Join(mismatched, ", ")
+	if len(errored) > 0 {
+		msg += "; failed to run in: " + strings.Join(errored, ", ")
+	}
+	return msg
+}
+
+// Unwrap returns the per-context errors, so errors.Is and errors.As see
+// each of them.
+func (m *MultiError) Unwrap() []error {
+	errs := make([]error, len(m.Errors))
+	for i, e := range m.Errors {
+		errs[i] = e
+	}
+	return errs
+}
+
+// Is reports whether target is ErrExecutionFailed.
+func (m *MultiError) Is(target error) bool {
+	return target == ErrExecutionFailed
+}
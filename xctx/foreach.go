@@ -0,0 +1,64 @@
+package xctx
+
+import "context"
+
+// ForEachOptions controls ForEach.
+type ForEachOptions struct {
+	// Parallel starts every call at once instead of one after another.
+	Parallel bool
+	// StopAfter stops the fan-out once this many calls have failed: no
+	// further call starts, and with Parallel the calls still running see
+	// their context cancelled. Zero means never.
+	StopAfter int
+	// Done, if set, is called with each index once its call returns, in
+	// completion order. Calls never overlap, even with Parallel.
+	Done func(i int)
+}
+
+// ForEach calls run for each of names with its index, and reports whether
+// StopAfter stopped the fan-out. run reports whether its call failed.
+//
+// Run uses ForEach, and the kubectl-xctx plugin runs its contexts through
+// it too. Once ctx is done no further call starts; with Parallel every call
+// has already started, so run should check its ctx before doing any work.
+func ForEach(ctx context.Context, names []string, opts ForEachOptions, run func(ctx context.Context, i int, name string) (failed bool)) (stopped bool) {
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	failures := 0
+	finish := func(i int, failed bool) {
+		if failed {
+			failures++
+			if failures == opts.StopAfter {
+				stop()
+			}
+		}
+		if opts.Done != nil {
+			opts.Done(i)
+		}
+	}
+
+	if opts.Parallel {
+		type outcome struct {
+			i      int
+			failed bool
+		}
+		done := make(chan outcome, len(names))
+		for i, name := range names {
+			go func() { done <- outcome{i, run(stopCtx, i, name)} }()
+		}
+		// Outcomes arrive as calls return; finishing them here, one at a
+		// time, is what keeps Done calls from overlapping.
+		for range names {
+			o := <-done
+			finish(o.i, o.failed)
+		}
+	} else {
+		for i, name := range names {
+			if stopCtx.Err() != nil {
+				break
+			}
+			finish(i, run(stopCtx, i, name))
+		}
+	}
+	return ctx.Err() == nil && stopCtx.Err() != nil
+}
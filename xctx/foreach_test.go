@@ -0,0 +1,60 @@
+package xctx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEach_StopAfter(t *testing.T) {
+	names := []string{"a", "fail-b", "c", "d"}
+	for _, parallel := range []bool{false, true} {
+		var ran []string
+		var cancelled atomic.Int64
+		stopped := ForEach(context.Background(), names, ForEachOptions{
+			Parallel:  parallel,
+			StopAfter: 1,
+			Done:      func(i int) { ran = append(ran, names[i]) },
+		}, func(ctx context.Context, _ int, name string) bool {
+			if strings.HasPrefix(name, "fail-") {
+				return true
+			}
+			if parallel {
+				<-ctx.Done()
+				cancelled.Add(1)
+			}
+			return false
+		})
+		if !stopped {
+			t.Errorf("parallel=%v: expected the first failure to stop the fan-out", parallel)
+		}
+		if !parallel && strings.Join(ran, ",") != "a,fail-b" {
+			t.Errorf("expected nothing to start after the failure, ran %q", ran)
+		}
+		if parallel && cancelled.Load() != 3 {
+			t.Errorf("expected the 3 running calls to be cancelled, got %d", cancelled.Load())
+		}
+	}
+}
+
+func TestRun_SequentialStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	results, err := Run(ctx, Options{
+		Contexts: []string{"prod-us-east", "staging-us", "dev-local"},
+		Args:     []string{"get", "pods"},
+		Runner: func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+			calls++
+			cancel()
+			return fakeRunner(ctx, args...)
+		},
+	})
+	if calls != 1 || len(results) != 1 {
+		t.Errorf("expected no context to start after cancellation, got %d call(s) and %d result(s)", calls, len(results))
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package xctx runs a kubectl command across several kubeconfig contexts. It
+// is the core of the kubectl-xctx plugin, for programs that want the fan-out
+// without the CLI.
+package xctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Runner executes kubectl with args and returns its output.
+type Runner func(ctx context.Context, args ...string) (stdout, stderr []byte, err error)
+
+// Command returns the exec.Cmd running bin, or kubectl on PATH when bin is
+// empty, with args and the env KEY=VALUE pairs added to the inherited
+// environment. ExecRunner runs it; callers needing other plumbing, such as
+// stdin or streamed output, can set that up before running it themselves.
+func Command(ctx context.Context, bin string, env, args []string) *exec.Cmd {
+	if bin == "" {
+		bin = "kubectl"
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	if len(env) > 0 {
+		// Later entries win, so the pairs override inherited values.
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+// ExecRunner returns a Runner that executes bin, or kubectl on PATH when bin
+// is empty, with the env KEY=VALUE pairs added to the inherited environment.
+func ExecRunner(bin string, env []string) Runner {
+	return func(ctx context.Context, args ...string) ([]byte, []byte, error) {
+		cmd := Command(ctx, bin, env, args)
+		var outBuf, errBuf strings.Builder
+		cmd.Stdout = &outBuf
+		cmd.Stderr = &errBuf
+		err := cmd.Run()
+		return []byte(outBuf.String()), []byte(errBuf.String()), err
+	}
+}
+
+// DeadlineError returns err from a kubectl run under ctx, marked with
+// context.DeadlineExceeded when ctx's deadline is what stopped it: kubectl
+// is killed when the deadline fires, and the deadline is the better report.
+func DeadlineError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w (%v)", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// Options controls a Run.
+type Options struct {
+	// Pattern is a regular expression selecting contexts by name. It is
+	// ignored when Contexts is set.
+	Pattern string
+	// Contexts names the contexts to run in, in order.
+	Contexts []string
+	// Args is the kubectl command, run after "--context <name>".
+	Args []string
+	// Parallel runs every context at once instead of one after another.
+	Parallel bool
+	// Timeout bounds each context's run; zero means no limit.
+	Timeout time.Duration
+	// Runner executes kubectl; nil means ExecRunner("", nil).
+	Runner Runner
+	// OnResult, if set, is called with each context's Result as soon as it
+	// completes, in completion order. Calls never overlap, even with
+	// Parallel.
+	OnResult func(Result)
+}
+
+// Result is the outcome of running the command in one context.
+type Result struct {
+	Context  string
+	Stdout   []byte
+	Stderr   []byte
+	Err      error
+	Duration time.Duration
+}
+
+// Run runs opts.Args in every selected context and returns one Result per
+// context, in selection order. When any context fails, the error is a
+// *MultiError naming them; the results are returned either way. Once ctx is
+// cancelled no further context starts, and Run returns the results so far
+// with ctx's error.
+func Run(ctx context.Context, opts Options) ([]Result, error) {
+	if len(opts.Args) == 0 {
+		return nil, errors.New("no kubectl command given")
+	}
+	runner := opts.Runner
+	if runner == nil {
+		runner = ExecRunner("", nil)
+	}
+	contexts, err := selectContexts(ctx, runner, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(contexts))
+	ran := make([]bool, len(contexts))
+	ForEach(ctx, contexts, ForEachOptions{
+		Parallel: opts.Parallel,
+		Done: func(i int) {
+			if ran[i] && opts.OnResult != nil {
+				opts.OnResult(results[i])
+			}
+		},
+	}, func(ctx context.Context, i int, name string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		results[i], ran[i] = runInContext(ctx, runner, name, opts), true
+		return results[i].Err != nil
+	})
+	if err := ctx.Err(); err != nil {
+		// Contexts left unrun by the cancellation get no Result.
+		var started []Result
+		for i, r := range results {
+			if ran[i] {
+				started = append(started, r)
+			}
+		}
+		return started, err
+	}
+
+	var m MultiError
+	for _, r := range results {
+		if r.Err != nil {
+			m.Errors = append(m.Errors, &ContextError{Context: r.Context, Err: r.Err})
+		}
+	}
+	if len(m.Errors) > 0 {
+		return results, &m
+	}
+	return results, nil
+}
+
+// selectContexts returns opts.Contexts, or the contexts matching
+// opts.Pattern when none are named.
+func selectContexts(ctx context.Context, runner Runner, opts Options) ([]string, error) {
+	if len(opts.Contexts) > 0 {
+		return opts.Contexts, nil
+	}
+	re, err := regexp.Compile(opts.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, opts.Pattern, err)
+	}
+	return MatchContexts(ctx, runner, re)
+}
+
+// runInContext runs opts.Args in name, within opts.Timeout.
+func runInContext(ctx context.Context, runner Runner, name string, opts Options) Result {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	stdout, stderr, err := runner(ctx, append([]string{"--context", name}, opts.Args...)...)
+	return Result{Context: name, Stdout: stdout, Stderr: stderr, Err: DeadlineError(ctx, err), Duration: time.Since(start)}
+}
@@ -0,0 +1,163 @@
+package xctx
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeContextList is the set of contexts returned by fakeRunner.
+const fakeContextList = "prod-us-east\nprod-eu-west\nstaging-us\ndev-local"
+
+// fakeRunner lists fakeContextList and answers any other command with
+// "result from <ctx>\n". Contexts named "fail-*" exit non-zero, and those
+// named "slow-*" block until their deadline.
+func fakeRunner(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	if args[0] == "config" {
+		return []byte(fakeContextList), nil, nil
+	}
+	name := args[1]
+	switch {
+	case strings.HasPrefix(name, "fail-"):
+		return nil, []byte("boom\n"), errors.New("exit status 1")
+	case strings.HasPrefix(name, "slow-"):
+		<-ctx.Done()
+		return nil, nil, errors.New("signal: killed")
+	}
+	return []byte("result from " + name + "\n"), nil, nil
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		opts       Options
+		wantOrder  []string
+		wantFailed []string
+		wantErr    string
+	}{
+		{
+			name:      "pattern",
+			opts:      Options{Pattern: "prod", Args: []string{"get", "pods"}},
+			wantOrder: []string{"prod-us-east", "prod-eu-west"},
+		},
+		{
+			name:      "explicit contexts keep their order",
+			opts:      Options{Contexts: []string{"staging-us", "dev-local"}, Args: []string{"get", "pods"}},
+			wantOrder: []string{"staging-us", "dev-local"},
+		},
+		{
+			name:      "parallel keeps selection order",
+			opts:      Options{Pattern: ".", Args: []string{"get", "pods"}, Parallel: true},
+			wantOrder: []string{"prod-us-east", "prod-eu-west", "staging-us", "dev-local"},
+		},
+		{
+			name:       "failures",
+			opts:       Options{Contexts: []string{"prod-us-east", "fail-a", "fail-b"}, Args: []string{"get", "pods"}, Parallel: true},
+			wantOrder:  []string{"prod-us-east", "fail-a", "fail-b"},
+			wantFailed: []string{"fail-a", "fail-b"},
+			wantErr:    "2 context(s) failed",
+		},
+		{
+			name:       "timeout",
+			opts:       Options{Contexts: []string{"slow-ctx", "dev-local"}, Args: []string{"get", "pods"}, Timeout: 10 * time.Millisecond},
+			wantOrder:  []string{"slow-ctx", "dev-local"},
+			wantFailed: []string{"slow-ctx"},
+			wantErr:    "1 context(s) failed",
+		},
+		{
+			name:    "no command",
+			opts:    Options{Pattern: "."},
+			wantErr: "no kubectl command given",
+		},
+		{
+			name:    "invalid pattern",
+			opts:    Options{Pattern: "[", Args: []string{"get", "pods"}},
+			wantErr: `invalid pattern "["`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Runner = fakeRunner
+			results, err := Run(context.Background(), tc.opts)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.wantErr)) {
+				t.Fatalf("want error %q, got %v", tc.wantErr, err)
+			}
+
+			var order, failed []string
+			for _, r := range results {
+				order = append(order, r.Context)
+				if r.Err != nil {
+					failed = append(failed, r.Context)
+				} else if string(r.Stdout) != "result from "+r.Context+"\n" {
+					t.Errorf("context %q: unexpected stdout %q", r.Context, r.Stdout)
+				}
+			}
+			if strings.Join(order, ",") != strings.Join(tc.wantOrder, ",") {
+				t.Errorf("want results for %q, got %q", tc.wantOrder, order)
+			}
+			if strings.Join(failed, ",") != strings.Join(tc.wantFailed, ",") {
+				t.Errorf("want failures in %q, got %q", tc.wantFailed, failed)
+			}
+
+			var m *MultiError
+			if len(tc.wantFailed) > 0 && (!errors.As(err, &m) || len(m.Errors) != len(tc.wantFailed)) {
+				t.Errorf("expected a *MultiError with %d error(s), got %v", len(tc.wantFailed), err)
+			}
+		})
+	}
+}
+
+func TestRun_TimeoutReportsDeadline(t *testing.T) {
+	results, _ := Run(context.Background(), Options{
+		Contexts: []string{"slow-ctx"},
+		Args:     []string{"get", "pods"},
+		Timeout:  10 * time.Millisecond,
+		Runner:   fakeRunner,
+	})
+	if len(results) != 1 || !errors.Is(results[0].Err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %+v", results)
+	}
+}
+
+func TestRun_OnResult(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		var seen []string
+		inFlight := 0
+		_, _ = Run(context.Background(), Options{
+			Pattern:  ".",
+			Args:     []string{"get", "pods"},
+			Parallel: parallel,
+			Runner:   fakeRunner,
+			OnResult: func(r Result) {
+				// Unsynchronized on purpose: the race detector and this
+				// counter catch overlapping calls.
+				inFlight++
+				defer func() { inFlight-- }()
+				if inFlight > 1 {
+					t.Error("OnResult calls overlapped")
+				}
+				seen = append(seen, r.Context)
+			},
+		})
+		sort.Strings(seen)
+		if want := "dev-local,prod-eu-west,prod-us-east,staging-us"; strings.Join(seen, ",") != want {
+			t.Errorf("parallel=%v: want one callback per context (%s), got %q", parallel, want, seen)
+		}
+	}
+}
+
+func TestRun_ErrorCategories(t *testing.T) {
+	_, err := Run(context.Background(), Options{Pattern: "[", Args: []string{"get", "pods"}, Runner: fakeRunner})
+	if !errors.Is(err, ErrInvalidPattern) || errors.Is(err, ErrExecutionFailed) {
+		t.Errorf("expected only ErrInvalidPattern, got %v", err)
+	}
+	_, err = Run(context.Background(), Options{Contexts: []string{"fail-a"}, Args: []string{"get", "pods"}, Runner: fakeRunner})
+	if !errors.Is(err, ErrExecutionFailed) || errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected only ErrExecutionFailed, got %v", err)
+	}
+}